@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vibetunnel/linux/pkg/termsocket"
+)
+
+// defaultEventsTimeout is how long a GET /api/events long poll waits for a
+// new event before returning an empty result when the client doesn't pass
+// its own timeout query param.
+const defaultEventsTimeout = 30 * time.Second
+
+// EventsHandler serves GET /api/events?since=N&session=S&timeout=30s, a
+// Syncthing-style cursor-paginated long poll over termsocket.Manager's
+// event log, so a client (or a `vt events` CLI) can resume across
+// reconnects without racing the push-based subscriber model.
+type EventsHandler struct {
+	termManager *termsocket.Manager
+}
+
+func NewEventsHandler(termManager *termsocket.Manager) *EventsHandler {
+	return &EventsHandler{
+		termManager: termManager,
+	}
+}
+
+func (h *EventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var sinceID uint64
+	if v := query.Get("since"); v != "" {
+		parsed, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		sinceID = parsed
+	}
+
+	var sessionFilter []string
+	if v := query.Get("session"); v != "" {
+		sessionFilter = strings.Split(v, ",")
+	}
+
+	timeout := defaultEventsTimeout
+	if v := query.Get("timeout"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			http.Error(w, "invalid timeout", http.StatusBadRequest)
+			return
+		}
+		timeout = parsed
+	}
+
+	events, err := h.termManager.Events(sinceID, sessionFilter, timeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []termsocket.Event{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		log.Printf("[Events] Failed to encode response: %v", err)
+	}
+}
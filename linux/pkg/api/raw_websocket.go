@@ -124,7 +124,7 @@ func (h *RawTerminalWebSocketHandler) subscribeToRawPTY(sessionID string, send c
 	var dataMutex sync.Mutex
 	
 	// Register for direct raw PTY callbacks (goterm-style)
-	h.manager.RegisterRawPTYCallback(sessionID, func(sid string, data []byte) {
+	subID := h.manager.RegisterRawPTYCallback(sessionID, func(sid string, data []byte) {
 		dataMutex.Lock()
 		defer dataMutex.Unlock()
 		
@@ -157,7 +157,7 @@ func (h *RawTerminalWebSocketHandler) subscribeToRawPTY(sessionID string, send c
 	dataMutex.Unlock()
 	
 	// Unregister callback when done
-	h.manager.UnregisterRawPTYCallback(sessionID)
+	h.manager.UnregisterRawPTYCallback(sessionID, subID)
 }
 
 // calculateSimilarity returns a value between 0.0 and 1.0 indicating how similar two byte arrays are
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+// SSETerminalHandler streams a session's raw PTY output over
+// text/event-stream as a WebSocket-free alternative to
+// RawTerminalWebSocketHandler. Input still goes through the existing REST
+// endpoints; this handler is output-only.
+type SSETerminalHandler struct {
+	manager *session.Manager
+}
+
+func NewSSETerminalHandler(manager *session.Manager) *SSETerminalHandler {
+	return &SSETerminalHandler{
+		manager: manager,
+	}
+}
+
+func (h *SSETerminalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("sessionId")
+	if sessionID == "" {
+		http.Error(w, "sessionId is required", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// Replay everything since Last-Event-ID (sent either as the standard
+	// header on reconnect, or as a query param for the initial curl/EventSource
+	// connection) so a client sees no gap across a reconnect, as long as the
+	// chunks it missed are still within the manager's retained output
+	// history; older chunks have been evicted and are not replayed.
+	var sinceID uint64
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		sinceID, _ = strconv.ParseUint(lastID, 10, 64)
+	} else if q := r.URL.Query().Get("lastEventId"); q != "" {
+		sinceID, _ = strconv.ParseUint(q, 10, 64)
+	}
+
+	backlog, _ := h.manager.OutputSince(sessionID, sinceID)
+	for _, chunk := range backlog {
+		if !writeSSEChunk(w, flusher, chunk) {
+			return
+		}
+		sinceID = chunk.ID
+	}
+
+	// The callback only wakes the loop below; OutputSince is the single
+	// source of truth for which chunks to send, so there's no shared state
+	// between the PTY reader goroutine (which invokes the callback) and
+	// this handler's goroutine (the only thing that ever advances sinceID).
+	woken := make(chan struct{}, 1)
+	subID := h.manager.RegisterRawPTYCallback(sessionID, func(sid string, data []byte) {
+		select {
+		case woken <- struct{}{}:
+		default:
+		}
+	})
+	defer h.manager.UnregisterRawPTYCallback(sessionID, subID)
+
+	done := r.Context().Done()
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-woken:
+			chunks, _ := h.manager.OutputSince(sessionID, sinceID)
+			for _, chunk := range chunks {
+				if !writeSSEChunk(w, flusher, chunk) {
+					return
+				}
+				sinceID = chunk.ID
+			}
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-done:
+			return
+		}
+	}
+}
+
+// writeSSEChunk writes one chunk as an SSE event with base64-encoded data
+// and an incrementing id: field, flushing immediately so it reaches the
+// client without buffering.
+func writeSSEChunk(w http.ResponseWriter, flusher http.Flusher, chunk session.OutputChunk) bool {
+	encoded := base64.StdEncoding.EncodeToString(chunk.Data)
+	if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", chunk.ID, encoded); err != nil {
+		return false
+	}
+	flusher.Flush()
+	return true
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+	"github.com/vibetunnel/linux/pkg/session"
+	"github.com/vibetunnel/linux/pkg/termsocket"
+)
+
+// ttyShareFrame is one message of the tty-share wire protocol: a JSON
+// envelope carrying a base64 payload whose meaning depends on Type.
+type ttyShareFrame struct {
+	Type string `json:"Type"`
+	Data string `json:"Data"`
+}
+
+// ttyShareWinSize is the WinSize frame payload. TTYShareHandler sends it
+// once, on attach; a later resize of the session sends no new WinSize frame.
+type ttyShareWinSize struct {
+	Rows int `json:"Rows"`
+	Cols int `json:"Cols"`
+}
+
+// TTYShareHandler exposes a session over the tty-share wire protocol so any
+// tty-share CLI client can attach as a read-only viewer without the web UI.
+// It only ever sends Write/WriteZlib/WinSize frames; any frame received
+// from the client is rejected, since this is a view-only attachment.
+type TTYShareHandler struct {
+	sessionManager *session.Manager
+	termManager    *termsocket.Manager
+}
+
+func NewTTYShareHandler(sessionManager *session.Manager, termManager *termsocket.Manager) *TTYShareHandler {
+	return &TTYShareHandler{
+		sessionManager: sessionManager,
+		termManager:    termManager,
+	}
+}
+
+func (h *TTYShareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("sessionId")
+	if sessionID == "" {
+		http.Error(w, "sessionId is required", http.StatusBadRequest)
+		return
+	}
+
+	sess, err := h.sessionManager.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	if token := sess.GetConfig().ViewerToken; token != "" && r.URL.Query().Get("token") != token {
+		http.Error(w, "invalid viewer token", http.StatusForbidden)
+		return
+	}
+
+	useZlib := r.URL.Query().Get("zlib") == "1"
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("[TTYShare] Failed to upgrade connection: %v", err)
+		return
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("[TTYShare] Failed to close connection: %v", err)
+		}
+	}()
+
+	done := make(chan struct{})
+	closeDone := func() {
+		select {
+		case <-done:
+		default:
+			close(done)
+		}
+	}
+
+	// Reject anything the client sends - this attachment is view-only. A
+	// disconnect is usually noticed here first (the client just stops
+	// sending, or the read errors out), so it must close done itself
+	// rather than leaving that to the write path below, or an idle,
+	// silent session would never unblock <-done.
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				closeDone()
+				return
+			}
+		}
+	}()
+
+	info := sess.GetInfo()
+	if err := writeWinSize(conn, info.Height, info.Width); err != nil {
+		return
+	}
+
+	if replay, err := h.replayScreen(sessionID); err == nil && len(replay) > 0 {
+		if err := writeTTYShareData(conn, replay, useZlib); err != nil {
+			return
+		}
+	}
+
+	subID := h.sessionManager.RegisterRawPTYCallback(sessionID, func(sid string, data []byte) {
+		if err := writeTTYShareData(conn, data, useZlib); err != nil {
+			closeDone()
+		}
+	})
+	defer h.sessionManager.UnregisterRawPTYCallback(sessionID, subID)
+
+	<-done
+}
+
+// replayScreen renders the session's current terminal buffer as plain text
+// (one line per row, cursor positioned last) so an attaching viewer sees
+// the current screen before live output starts streaming.
+func (h *TTYShareHandler) replayScreen(sessionID string) ([]byte, error) {
+	snapshot, err := h.termManager.GetBufferSnapshot(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[2J\x1b[H")
+	for _, row := range snapshot.Cells {
+		for _, cell := range row {
+			if cell.Char == 0 {
+				buf.WriteRune(' ')
+			} else {
+				buf.WriteRune(cell.Char)
+			}
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "\x1b[%d;%dH", snapshot.CursorY+1, snapshot.CursorX+1)
+
+	return buf.Bytes(), nil
+}
+
+// writeWinSize sends a WinSize frame for the given terminal dimensions.
+// WinSize is always sent uncompressed; only Write frames honor ?zlib=1.
+func writeWinSize(conn *websocket.Conn, rows, cols int) error {
+	payload, err := json.Marshal(ttyShareWinSize{Rows: rows, Cols: cols})
+	if err != nil {
+		return err
+	}
+	return writeTTYShareFrame(conn, "WinSize", payload, false)
+}
+
+// writeTTYShareData sends raw PTY bytes as a Write (or, if useZlib is set,
+// WriteZlib) frame.
+func writeTTYShareData(conn *websocket.Conn, data []byte, useZlib bool) error {
+	return writeTTYShareFrame(conn, "Write", data, useZlib)
+}
+
+// writeTTYShareFrame base64-encodes payload (zlib-compressing it first when
+// compress is true, switching the frame type to WriteZlib) and writes it as
+// a JSON tty-share frame.
+func writeTTYShareFrame(conn *websocket.Conn, frameType string, payload []byte, compress bool) error {
+	if compress {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		if _, err := zw.Write(payload); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		payload = buf.Bytes()
+		frameType = "WriteZlib"
+	}
+
+	frame := ttyShareFrame{
+		Type: frameType,
+		Data: base64.StdEncoding.EncodeToString(payload),
+	}
+	encoded, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, encoded)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/vibetunnel/linux/pkg/termsocket"
+)
+
+// WatchAdminHandler serves GET/DELETE /api/watches?sessionId=S[&id=W], so an
+// operator can enumerate a session's live buffer-change watches and
+// forcibly evict one - useful for debugging stuck clients and for
+// enforcing per-user quotas.
+type WatchAdminHandler struct {
+	termManager *termsocket.Manager
+}
+
+func NewWatchAdminHandler(termManager *termsocket.Manager) *WatchAdminHandler {
+	return &WatchAdminHandler{
+		termManager: termManager,
+	}
+}
+
+func (h *WatchAdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.list(w, r)
+	case http.MethodDelete:
+		h.evict(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *WatchAdminHandler) list(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("sessionId")
+	if sessionID == "" {
+		http.Error(w, "sessionId is required", http.StatusBadRequest)
+		return
+	}
+
+	watches := h.termManager.ListWatches(sessionID)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(watches); err != nil {
+		log.Printf("[WatchAdmin] Failed to encode response: %v", err)
+	}
+}
+
+func (h *WatchAdminHandler) evict(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+
+	h.termManager.CloseWatch(id)
+	w.WriteHeader(http.StatusNoContent)
+}
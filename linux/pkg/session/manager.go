@@ -1,6 +1,7 @@
 package session
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -10,26 +11,111 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
+// StatusDraining marks a session whose PTY leader has been signalled to
+// exit as part of a Manager.Shutdown drain period, so ListSessions can
+// surface it to the UI before the process actually exits.
+const StatusDraining = "draining"
+
+// ErrManagerShuttingDown is returned by CreateSession/CreateSessionWithID
+// once Shutdown has been called; no new sessions are accepted during the
+// drain period.
+var ErrManagerShuttingDown = fmt.Errorf("session manager is shutting down")
+
 // DirectOutputCallback is called when PTY output is available
 type DirectOutputCallback func(sessionID string, data []byte)
 
+// RawPTYCallback is called with raw PTY bytes exactly as produced, with no
+// debouncing applied.
+type RawPTYCallback func(sessionID string, data []byte)
+
+// SubscriptionID identifies a single Subscribe call so it can be targeted
+// for removal by Unsubscribe, independent of any other subscription on the
+// same session.
+type SubscriptionID uint64
+
+// SubscribeOptions selects how a subscription's callback is driven.
+type SubscribeOptions struct {
+	// Raw delivers every PTY write immediately and synchronously, with no
+	// debouncing - used by consumers that need byte-exact, low-latency
+	// output (e.g. the raw WebSocket handler).
+	Raw bool
+	// DebounceMs coalesces bursts of output into one callback at most every
+	// DebounceMs milliseconds. Ignored when Raw is true.
+	DebounceMs int
+}
+
+// subscription is one registered callback plus the options it was
+// registered with, and (when opts.DebounceMs > 0) the coalescing state
+// deliver uses to batch bursts into one callback per debounce window.
+type subscription struct {
+	callback DirectOutputCallback
+	opts     SubscribeOptions
+
+	debounceMu      sync.Mutex
+	debouncePending []byte
+	debounceTimer   *time.Timer
+}
+
+// deliver invokes callback with data, either immediately in its own
+// goroutine (the default, DebounceMs <= 0) or coalesced with any other data
+// delivered within the same DebounceMs window into a single callback.
+func (s *subscription) deliver(sessionID string, data []byte) {
+	if s.opts.DebounceMs <= 0 {
+		go s.callback(sessionID, data)
+		return
+	}
+
+	s.debounceMu.Lock()
+	defer s.debounceMu.Unlock()
+
+	s.debouncePending = append(s.debouncePending, data...)
+	if s.debounceTimer != nil {
+		return
+	}
+	s.debounceTimer = time.AfterFunc(time.Duration(s.opts.DebounceMs)*time.Millisecond, func() {
+		s.debounceMu.Lock()
+		pending := s.debouncePending
+		s.debouncePending = nil
+		s.debounceTimer = nil
+		s.debounceMu.Unlock()
+
+		if len(pending) > 0 {
+			s.callback(sessionID, pending)
+		}
+	})
+}
+
 type Manager struct {
 	controlPath         string
 	runningSessions     map[string]*Session
 	mutex               sync.RWMutex
 	doNotAllowColumnSet bool
-	directOutputCallbacks map[string][]DirectOutputCallback
-	callbackMutex       sync.RWMutex
+	draining            bool
+
+	// subscriptions holds every Subscribe'd callback, keyed by session then
+	// by the SubscriptionID returned to the caller, so Unsubscribe can
+	// remove exactly one without touching any other subscriber.
+	subscriptions map[string]map[SubscriptionID]*subscription
+	callbackMutex sync.RWMutex
+	nextSubID     uint64
+
+	// history retains a short ring buffer of raw PTY output per session so
+	// late subscribers (e.g. an SSE client resuming with Last-Event-ID) can
+	// replay what they missed instead of losing it.
+	history map[string]*outputHistory
 }
 
 func NewManager(controlPath string) *Manager {
 	return &Manager{
-		controlPath:           controlPath,
-		runningSessions:       make(map[string]*Session),
-		directOutputCallbacks: make(map[string][]DirectOutputCallback),
+		controlPath:     controlPath,
+		runningSessions: make(map[string]*Session),
+		subscriptions:   make(map[string]map[SubscriptionID]*subscription),
+		history:         make(map[string]*outputHistory),
 	}
 }
 
@@ -48,6 +134,13 @@ func (m *Manager) GetDoNotAllowColumnSet() bool {
 }
 
 func (m *Manager) CreateSession(config Config) (*Session, error) {
+	m.mutex.RLock()
+	draining := m.draining
+	m.mutex.RUnlock()
+	if draining {
+		return nil, ErrManagerShuttingDown
+	}
+
 	if err := os.MkdirAll(m.controlPath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create control directory: %w", err)
 	}
@@ -81,6 +174,13 @@ func (m *Manager) CreateSession(config Config) (*Session, error) {
 }
 
 func (m *Manager) CreateSessionWithID(id string, config Config) (*Session, error) {
+	m.mutex.RLock()
+	draining := m.draining
+	m.mutex.RUnlock()
+	if draining {
+		return nil, ErrManagerShuttingDown
+	}
+
 	if err := os.MkdirAll(m.controlPath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create control directory: %w", err)
 	}
@@ -172,6 +272,15 @@ func (m *Manager) ListSessions() ([]*Info, error) {
 			}
 		}
 
+		// During a Shutdown drain period, surface still-running sessions as
+		// draining rather than their ordinary running status.
+		m.mutex.RLock()
+		draining := m.draining
+		m.mutex.RUnlock()
+		if draining && session.info.Status != string(StatusExited) {
+			session.info.Status = StatusDraining
+		}
+
 		sessions = append(sessions, session.info)
 	}
 
@@ -199,34 +308,7 @@ func (m *Manager) RemoveExitedSessions() error {
 	var errs []error
 	for _, info := range sessions {
 		// Check if the process is actually alive, not just the stored status
-		shouldRemove := false
-
-		if info.Pid == 0 {
-			// No PID recorded, consider it exited
-			shouldRemove = true
-		} else {
-			// Use ps command to check process status (portable across Unix systems)
-			cmd := exec.Command("ps", "-p", strconv.Itoa(info.Pid), "-o", "stat=")
-			output, err := cmd.Output()
-
-			if err != nil {
-				// Process doesn't exist
-				shouldRemove = true
-			} else {
-				// Check if it's a zombie process (status starts with 'Z')
-				stat := strings.TrimSpace(string(output))
-				if strings.HasPrefix(stat, "Z") {
-					// It's a zombie, should remove
-					shouldRemove = true
-
-					// Try to reap the zombie
-					var status syscall.WaitStatus
-					if _, err := syscall.Wait4(info.Pid, &status, syscall.WNOHANG, nil); err != nil {
-						log.Printf("[WARN] Failed to reap zombie process %d: %v", info.Pid, err)
-					}
-				}
-			}
-		}
+		shouldRemove := info.Pid == 0 || reapIfExited(info.Pid)
 
 		if shouldRemove {
 			sessionPath := filepath.Join(m.controlPath, info.ID)
@@ -245,6 +327,30 @@ func (m *Manager) RemoveExitedSessions() error {
 	return nil
 }
 
+// reapIfExited checks whether pid is gone or a zombie (portable across Unix
+// systems via `ps`), reaping it with syscall.Wait4 if it's a zombie. It
+// returns true if the process should be considered exited.
+func reapIfExited(pid int) bool {
+	cmd := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "stat=")
+	output, err := cmd.Output()
+	if err != nil {
+		// Process doesn't exist
+		return true
+	}
+
+	// Check if it's a zombie process (status starts with 'Z')
+	stat := strings.TrimSpace(string(output))
+	if !strings.HasPrefix(stat, "Z") {
+		return false
+	}
+
+	var status syscall.WaitStatus
+	if _, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err != nil {
+		log.Printf("[WARN] Failed to reap zombie process %d: %v", pid, err)
+	}
+	return true
+}
+
 // UpdateAllSessionStatuses updates the status of all sessions
 func (m *Manager) UpdateAllSessionStatuses() error {
 	sessions, err := m.ListSessions()
@@ -269,76 +375,257 @@ func (m *Manager) RemoveSession(id string) error {
 	delete(m.runningSessions, id)
 	m.mutex.Unlock()
 
-	// Remove direct output callbacks
+	// Remove any subscriptions and output history for this session
 	m.callbackMutex.Lock()
-	delete(m.directOutputCallbacks, id)
+	delete(m.subscriptions, id)
+	delete(m.history, id)
 	m.callbackMutex.Unlock()
 
 	sessionPath := filepath.Join(m.controlPath, id)
 	return os.RemoveAll(sessionPath)
 }
 
-// RegisterDirectOutputCallback registers a callback for direct PTY output (like Node.js)
-func (m *Manager) RegisterDirectOutputCallback(sessionID string, callback DirectOutputCallback) {
+// Subscribe registers callback to receive a session's PTY output and
+// returns an opaque SubscriptionID that Unsubscribe can later use to remove
+// this subscription without disturbing any other subscriber on the same
+// session. This is the single entry point behind the
+// RegisterDirectOutputCallback/RegisterRawPTYCallback convenience wrappers
+// below, which only differ in the SubscribeOptions they pass.
+func (m *Manager) Subscribe(sessionID string, opts SubscribeOptions, callback DirectOutputCallback) SubscriptionID {
+	id := SubscriptionID(atomic.AddUint64(&m.nextSubID, 1))
+
 	m.callbackMutex.Lock()
 	defer m.callbackMutex.Unlock()
-	
-	m.directOutputCallbacks[sessionID] = append(m.directOutputCallbacks[sessionID], callback)
+
+	if m.subscriptions[sessionID] == nil {
+		m.subscriptions[sessionID] = make(map[SubscriptionID]*subscription)
+	}
+	m.subscriptions[sessionID][id] = &subscription{callback: callback, opts: opts}
+
+	return id
 }
 
-// UnregisterDirectOutputCallback removes a callback for direct PTY output
-func (m *Manager) UnregisterDirectOutputCallback(sessionID string, callback DirectOutputCallback) {
+// Unsubscribe removes exactly the subscription identified by id, leaving
+// any other subscriber on the same session untouched.
+func (m *Manager) Unsubscribe(sessionID string, id SubscriptionID) {
 	m.callbackMutex.Lock()
 	defer m.callbackMutex.Unlock()
-	
-	callbacks := m.directOutputCallbacks[sessionID]
-	for i, cb := range callbacks {
-		// Compare function pointers (this is tricky, so we'll use a different approach)
-		// For now, we'll clear all callbacks when unregistering
-		_ = cb
-		if i == 0 {
-			m.directOutputCallbacks[sessionID] = nil
-			break
-		}
+
+	subs := m.subscriptions[sessionID]
+	delete(subs, id)
+	if len(subs) == 0 {
+		delete(m.subscriptions, sessionID)
 	}
 }
 
-// NotifyDirectOutput notifies all registered callbacks of new PTY output (like Node.js)
+// RegisterDirectOutputCallback subscribes to a session's PTY output with
+// default delivery semantics: each chunk is passed to callback immediately
+// in its own goroutine, with no debouncing, matching the original Node.js
+// PTY event behavior.
+func (m *Manager) RegisterDirectOutputCallback(sessionID string, callback DirectOutputCallback) SubscriptionID {
+	return m.Subscribe(sessionID, SubscribeOptions{}, callback)
+}
+
+// UnregisterDirectOutputCallback removes exactly the subscription returned
+// by the matching RegisterDirectOutputCallback call.
+func (m *Manager) UnregisterDirectOutputCallback(sessionID string, id SubscriptionID) {
+	m.Unsubscribe(sessionID, id)
+}
+
+// RegisterRawPTYCallback subscribes to a session's raw PTY bytes, delivered
+// synchronously with no debouncing (goterm-style).
+func (m *Manager) RegisterRawPTYCallback(sessionID string, callback RawPTYCallback) SubscriptionID {
+	return m.Subscribe(sessionID, SubscribeOptions{Raw: true}, DirectOutputCallback(callback))
+}
+
+// UnregisterRawPTYCallback removes exactly the subscription returned by the
+// matching RegisterRawPTYCallback call.
+func (m *Manager) UnregisterRawPTYCallback(sessionID string, id SubscriptionID) {
+	m.Unsubscribe(sessionID, id)
+}
+
+// NotifyDirectOutput delivers PTY output to every non-raw subscriber of a
+// session. Each subscriber is delivered to via its own deliver call, so a
+// slow or debounced subscriber can't stall the PTY reader or any other
+// subscriber.
 func (m *Manager) NotifyDirectOutput(sessionID string, data []byte) {
 	m.callbackMutex.RLock()
-	callbacks := m.directOutputCallbacks[sessionID]
+	subs := m.subscriptions[sessionID]
+	targets := make([]*subscription, 0, len(subs))
+	for _, sub := range subs {
+		if !sub.opts.Raw {
+			targets = append(targets, sub)
+		}
+	}
+	m.callbackMutex.RUnlock()
+
+	for _, sub := range targets {
+		sub.deliver(sessionID, data)
+	}
+}
+
+// NotifyRawPTY delivers raw PTY output to every raw subscriber of a
+// session, synchronously and in registration order, for minimum latency.
+func (m *Manager) NotifyRawPTY(sessionID string, data []byte) {
+	m.recordOutput(sessionID, data)
+
+	m.callbackMutex.RLock()
+	subs := m.subscriptions[sessionID]
+	ids := make([]SubscriptionID, 0, len(subs))
+	for id, sub := range subs {
+		if sub.opts.Raw {
+			ids = append(ids, id)
+		}
+	}
+	// SubscriptionIDs are allocated in increasing order, so sorting them
+	// restores registration order lost by map iteration.
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	callbacks := make([]DirectOutputCallback, 0, len(ids))
+	for _, id := range ids {
+		callbacks = append(callbacks, subs[id].callback)
+	}
 	m.callbackMutex.RUnlock()
-	
-	// Call all registered callbacks immediately (like Node.js PTY events)
+
 	for _, callback := range callbacks {
-		go callback(sessionID, data) // Non-blocking to prevent slowdowns
+		callback(sessionID, data) // Direct call - no goroutine for raw speed
 	}
 }
 
-// RegisterRawPTYCallback registers a callback for raw PTY bytes (goterm-style)
-type RawPTYCallback func(sessionID string, data []byte)
+// outputHistoryCap bounds how many raw PTY chunks are retained per session -
+// enough for a brief reconnect window, not a full scrollback replay.
+const outputHistoryCap = 256
 
-var rawPTYCallbacks = make(map[string][]RawPTYCallback)
-var rawCallbackMutex sync.RWMutex
+// OutputChunk is one recorded slice of raw PTY output, stamped with a
+// monotonically increasing ID so a consumer can resume after an ID it has
+// already seen (e.g. SSE's Last-Event-ID).
+type OutputChunk struct {
+	ID   uint64
+	Data []byte
+}
 
-func (m *Manager) RegisterRawPTYCallback(sessionID string, callback RawPTYCallback) {
-	rawCallbackMutex.Lock()
-	defer rawCallbackMutex.Unlock()
-	rawPTYCallbacks[sessionID] = append(rawPTYCallbacks[sessionID], callback)
+// outputHistory is a fixed-capacity ring buffer of OutputChunk for one
+// session.
+type outputHistory struct {
+	chunks []OutputChunk
+	nextID uint64
 }
 
-func (m *Manager) UnregisterRawPTYCallback(sessionID string) {
-	rawCallbackMutex.Lock()
-	defer rawCallbackMutex.Unlock()
-	delete(rawPTYCallbacks, sessionID)
+// recordOutput appends data to sessionID's output history, evicting the
+// oldest chunk once outputHistoryCap is exceeded.
+func (m *Manager) recordOutput(sessionID string, data []byte) {
+	m.callbackMutex.Lock()
+	defer m.callbackMutex.Unlock()
+
+	h := m.history[sessionID]
+	if h == nil {
+		h = &outputHistory{}
+		m.history[sessionID] = h
+	}
+
+	h.nextID++
+	h.chunks = append(h.chunks, OutputChunk{ID: h.nextID, Data: append([]byte(nil), data...)})
+	if len(h.chunks) > outputHistoryCap {
+		h.chunks = h.chunks[len(h.chunks)-outputHistoryCap:]
+	}
 }
 
-func (m *Manager) NotifyRawPTY(sessionID string, data []byte) {
-	rawCallbackMutex.RLock()
-	callbacks := rawPTYCallbacks[sessionID]
-	rawCallbackMutex.RUnlock()
-	
-	for _, callback := range callbacks {
-		callback(sessionID, data) // Direct call - no goroutine for raw speed
+// OutputSince returns every retained raw PTY chunk for sessionID with an ID
+// greater than sinceID, in order, plus the latest ID recorded (0 if none
+// has been recorded yet). Chunks older than the retained window are simply
+// absent, as with any fixed-capacity ring buffer.
+func (m *Manager) OutputSince(sessionID string, sinceID uint64) ([]OutputChunk, uint64) {
+	m.callbackMutex.RLock()
+	defer m.callbackMutex.RUnlock()
+
+	h := m.history[sessionID]
+	if h == nil {
+		return nil, 0
 	}
+
+	var chunks []OutputChunk
+	for _, c := range h.chunks {
+		if c.ID > sinceID {
+			chunks = append(chunks, c)
+		}
+	}
+	return chunks, h.nextID
+}
+
+// Shutdown begins a lame-duck shutdown of the manager: new sessions are
+// rejected (see ErrManagerShuttingDown), every running session's PTY leader
+// is sent SIGHUP then SIGTERM and marked StatusDraining, and the call
+// blocks for up to drain (or until ctx is cancelled) waiting for them to
+// exit on their own. Pending output keeps flowing through every registered
+// callback for the entire drain window - each session's own PTY reader
+// goroutine keeps calling NotifyDirectOutput/NotifyRawPTY exactly as
+// before, so viewers see the final bytes before the process disappears.
+// Once the drain window ends, stragglers are SIGKILLed and reaped.
+func (m *Manager) Shutdown(ctx context.Context, drain time.Duration) error {
+	m.mutex.Lock()
+	m.draining = true
+	sessions := make([]*Session, 0, len(m.runningSessions))
+	for _, s := range m.runningSessions {
+		sessions = append(sessions, s)
+	}
+	m.mutex.Unlock()
+
+	for _, sess := range sessions {
+		info := sess.GetInfo()
+		if info.Pid == 0 || !sess.IsAlive() {
+			continue
+		}
+		if err := syscall.Kill(info.Pid, syscall.SIGHUP); err != nil {
+			log.Printf("[WARN] Failed to send SIGHUP to session %s (pid %d): %v", sess.ID, info.Pid, err)
+		}
+		if err := syscall.Kill(info.Pid, syscall.SIGTERM); err != nil {
+			log.Printf("[WARN] Failed to send SIGTERM to session %s (pid %d): %v", sess.ID, info.Pid, err)
+		}
+	}
+
+	deadline := time.NewTimer(drain)
+	defer deadline.Stop()
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+drainLoop:
+	for {
+		select {
+		case <-ctx.Done():
+			break drainLoop
+		case <-deadline.C:
+			break drainLoop
+		case <-ticker.C:
+			stillAlive := false
+			for _, sess := range sessions {
+				if sess.IsAlive() {
+					stillAlive = true
+					break
+				}
+			}
+			if !stillAlive {
+				break drainLoop
+			}
+		}
+	}
+
+	var errs []error
+	for _, sess := range sessions {
+		info := sess.GetInfo()
+		if info.Pid == 0 {
+			continue
+		}
+		if reapIfExited(info.Pid) {
+			continue
+		}
+		if err := syscall.Kill(info.Pid, syscall.SIGKILL); err != nil {
+			errs = append(errs, fmt.Errorf("session %s: failed to SIGKILL pid %d: %w", sess.ID, info.Pid, err))
+			continue
+		}
+		reapIfExited(info.Pid)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("shutdown errors: %v", errs)
+	}
+	return nil
 }
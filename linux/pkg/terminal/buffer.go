@@ -2,16 +2,74 @@ package terminal
 
 import (
 	"encoding/binary"
+	"strconv"
 	"sync"
 	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
 )
 
+// Cell flag bits. Bold/Italic/Underline/Inverse already use 0x01-0x10 in
+// handleSGR; FlagWideContinuation marks the right-hand cell of a
+// double-width glyph so the serializer can skip it. trimRowCells treats it
+// as a non-blank cell and keeps it.
+const (
+	FlagWideContinuation uint8 = 0x20
+)
+
+// Color encoding for BufferCell.Fg/Bg. The zero value means "default
+// terminal color" (no SGR color set, or reset via 39/49). colorRGBFlag and
+// colorPaletteFlag are high bits that make an explicit color - including
+// palette index 0 or RGB black, both of which are indistinguishable from
+// "unset" if stored bare - distinguishable from the default.
+const (
+	colorRGBFlag     uint32 = 0x01000000
+	colorPaletteFlag uint32 = 0x02000000
+	colorValueMask   uint32 = 0x00ffffff
+)
+
+// isDefaultColor reports whether a color value means "use the terminal's
+// default color" rather than an explicitly set one.
+func isDefaultColor(c uint32) bool {
+	return c == 0
+}
+
+// isRGBColor reports whether c is a packed 24-bit truecolor value.
+func isRGBColor(c uint32) bool {
+	return c&colorRGBFlag != 0
+}
+
+// packPaletteColor encodes an explicit palette index (0-255), including
+// index 0, as distinct from the unset/default value.
+func packPaletteColor(idx uint32) uint32 {
+	return colorPaletteFlag | (idx & 0xff)
+}
+
+// packRGBColor encodes a 24-bit truecolor value as distinct from the
+// unset/default value, even when r=g=b=0.
+func packRGBColor(r, g, b uint32) uint32 {
+	return colorRGBFlag | (r << 16) | (g << 8) | b
+}
+
 // BufferCell represents a single cell in the terminal buffer
 type BufferCell struct {
 	Char  rune
 	Fg    uint32 // Foreground color (RGB + flags)
 	Bg    uint32 // Background color (RGB + flags)
 	Flags uint8  // Bold, Italic, Underline, etc.
+	// Combining holds zero-width combining marks (e.g. accents) that were
+	// printed after this cell's base rune instead of advancing the cursor.
+	Combining []rune `json:",omitempty"`
+	// LinkID references BufferSnapshot.Links (1-based, 0 = no link) for an
+	// OSC 8 hyperlink active when this cell was printed.
+	LinkID uint32 `json:",omitempty"`
+}
+
+// Hyperlink is the OSC 8 link currently active while printing; cells
+// written while it's set are stamped with its LinkID.
+type Hyperlink struct {
+	ID  uint32
+	URI string
 }
 
 // BufferSnapshot represents the current state of the terminal buffer
@@ -28,16 +86,57 @@ type BufferSnapshot struct {
 	// State change tracking like vt10x
 	ChangeFlags   uint32 `json:",omitempty"`  // Bitmask of changes
 	SequenceID    uint64 `json:",omitempty"`  // Monotonic sequence for deduplication
+	// ViewportOffset is how many lines the client has scrolled up into
+	// scrollback (0 means the viewport is pinned to the live screen).
+	ViewportOffset int `json:",omitempty"`
+	// Images holds sixel/iTerm2 inline images currently placed on screen,
+	// anchored to the cell position they were drawn at.
+	Images []ImagePlacement `json:",omitempty"`
+	// Links is the OSC 8 hyperlink URI table; BufferCell.LinkID indexes
+	// into it 1-based (0 means "no link").
+	Links []string `json:",omitempty"`
 }
 
+// defaultScrollbackLines caps how many rows are retained once they scroll
+// off the top of the screen. The cap counts physical rows, not logical
+// lines: a long line that soft-wrapped over several rows uses one entry per
+// row.
+const defaultScrollbackLines = 10000
+
 // Change flags like vt10x
 const (
 	ChangedScreen uint32 = 1 << iota
 	ChangedCursor
 	ChangedTitle
 	ChangedSize
+	ChangedMode // Mouse tracking mode (or other terminal mode) changed
 )
 
+// MouseMode is a bitmask of the DEC private mouse-tracking modes currently
+// enabled. Several can be set at once (e.g. ?1002 + ?1006), mirroring how
+// real terminals layer tracking protocol and encoding independently.
+type MouseMode uint8
+
+const MouseModeNone MouseMode = 0
+
+const (
+	MouseModeX10         MouseMode = 1 << iota // ?1000: report button press only
+	MouseModeButtonEvent                       // ?1002: also report motion while a button is held
+	MouseModeAnyEvent                          // ?1003: report all motion regardless of buttons
+	MouseModeSGR                               // ?1006: SGR extended coordinate encoding
+	MouseModeURXVT                              // ?1015: urxvt extended coordinate encoding
+)
+
+// scrollbackLine is one row of cells: a row evicted off the top of the
+// screen into scrollback, or (inside Resize) a logical line being reflowed.
+// wrapped records whether this row soft-wraps onto the row below it (as
+// opposed to ending in a hard newline), the same meaning as
+// TerminalBuffer.wrapped.
+type scrollbackLine struct {
+	cells   []BufferCell
+	wrapped bool
+}
+
 // TerminalBuffer manages a virtual terminal buffer similar to xterm.js
 type TerminalBuffer struct {
 	mu        sync.RWMutex
@@ -47,7 +146,19 @@ type TerminalBuffer struct {
 	cursorX   int
 	cursorY   int
 	viewportY int
-	
+
+	// wrapped[i] is true when row i's last cell soft-wrapped onto row i+1
+	// (set from handlePrint). It is what lets Resize tell a long reflowed
+	// line apart from several hard-newlined ones.
+	wrapped []bool
+
+	// scrollback holds rows evicted from the top of the screen by scrollUp,
+	// one entry per physical row with its wrap flag, oldest first, capped at
+	// scrollbackCap entries.
+	scrollback    []scrollbackLine
+	scrollbackCap int
+	viewportOffset int // lines scrolled up into scrollback, 0 = pinned to live screen
+
 	// vt10x-style state tracking for deduplication
 	dirty        []bool           // Track which lines are dirty (like vt10x)
 	anydirty     bool            // Any changes at all
@@ -60,16 +171,63 @@ type TerminalBuffer struct {
 	currentFg    uint32
 	currentBg    uint32
 	currentFlags uint8
+
+	// Alternate screen support (CSI ?1049h etc.) - vim/less/htop swap onto
+	// this so their output never touches the primary scrollback.
+	altScreenActive bool
+	primary         primaryScreenState
+
+	// DECSC/DECRC (ESC 7 / ESC 8) saved cursor, independent of screen swap.
+	savedCursor      savedCursorState
+	hasSavedCursor   bool
+
+	// Decoded sixel/iTerm2 images currently placed on screen, keyed by a
+	// monotonically increasing id.
+	images    []ImagePlacement
+	nextImage uint32
+
+	// OSC 8 hyperlink state. currentLink is nil outside an active link;
+	// linkURIs is the snapshot's Links table, indexed by LinkID-1.
+	currentLink *Hyperlink
+	linkURIs    []string
+
+	// mouseMode tracks which DEC private mouse-tracking modes the running
+	// program has enabled via CSI ?1000/1002/1003/1006/1015h/l.
+	mouseMode MouseMode
+}
+
+// primaryScreenState stashes everything the alternate screen needs to
+// restore when the program switches back to the primary screen.
+type primaryScreenState struct {
+	buffer       [][]BufferCell
+	wrapped      []bool
+	dirty        []bool
+	cursorX      int
+	cursorY      int
+	currentFg    uint32
+	currentBg    uint32
+	currentFlags uint8
+}
+
+// savedCursorState is what ESC 7 (DECSC) captures and ESC 8 (DECRC) restores.
+type savedCursorState struct {
+	cursorX      int
+	cursorY      int
+	currentFg    uint32
+	currentBg    uint32
+	currentFlags uint8
 }
 
 // NewTerminalBuffer creates a new terminal buffer
 func NewTerminalBuffer(cols, rows int) *TerminalBuffer {
 	tb := &TerminalBuffer{
-		cols:   cols,
-		rows:   rows,
-		buffer: make([][]BufferCell, rows),
-		parser: NewAnsiParser(),
-		dirty:  make([]bool, rows), // vt10x-style dirty tracking
+		cols:          cols,
+		rows:          rows,
+		buffer:        make([][]BufferCell, rows),
+		parser:        NewAnsiParser(),
+		dirty:         make([]bool, rows), // vt10x-style dirty tracking
+		wrapped:       make([]bool, rows),
+		scrollbackCap: defaultScrollbackLines,
 	}
 
 	// Initialize buffer with empty cells
@@ -86,6 +244,7 @@ func NewTerminalBuffer(cols, rows int) *TerminalBuffer {
 	tb.parser.OnCsi = tb.handleCsi
 	tb.parser.OnOsc = tb.handleOsc
 	tb.parser.OnEscape = tb.handleEscape
+	tb.parser.OnDcs = tb.handleDcs
 
 	return tb
 }
@@ -150,16 +309,19 @@ func (tb *TerminalBuffer) GetSnapshot() *BufferSnapshot {
 	}
 
 	snapshot := &BufferSnapshot{
-		Cols:          tb.cols,
-		Rows:          tb.rows,
-		ViewportY:     tb.viewportY,
-		CursorX:       tb.cursorX,
-		CursorY:       tb.cursorY,
-		Cells:         cells,
-		ChangedLines:  changedLines,
-		IsIncremental: isIncremental,
-		ChangeFlags:   tb.changeFlags,
-		SequenceID:    tb.sequenceID,
+		Cols:           tb.cols,
+		Rows:           tb.rows,
+		ViewportY:      tb.viewportY,
+		CursorX:        tb.cursorX,
+		CursorY:        tb.cursorY,
+		Cells:          cells,
+		ChangedLines:   changedLines,
+		IsIncremental:  isIncremental,
+		ChangeFlags:    tb.changeFlags,
+		SequenceID:     tb.sequenceID,
+		ViewportOffset: tb.viewportOffset,
+		Images:         append([]ImagePlacement(nil), tb.images...),
+		Links:          append([]string(nil), tb.linkURIs...),
 	}
 
 	// Cache snapshot and reset changes like vt10x
@@ -178,6 +340,51 @@ func (tb *TerminalBuffer) resetChanges() {
 	tb.changeFlags = 0
 }
 
+// RestoreSnapshot re-hydrates the buffer's visible state (cells, cursor,
+// viewport, images and link table) from a previously captured snapshot,
+// e.g. one replayed from termsocket.Manager's on-disk journal after a
+// restart. It does not restore scrollback, alt-screen, or parser state -
+// only what a snapshot itself carries - and marks everything dirty so the
+// next GetSnapshot sends a full redraw to any viewer.
+func (tb *TerminalBuffer) RestoreSnapshot(snapshot *BufferSnapshot) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	if snapshot.Cols != tb.cols || snapshot.Rows != tb.rows {
+		tb.cols = snapshot.Cols
+		tb.rows = snapshot.Rows
+		tb.dirty = make([]bool, tb.rows)
+		tb.wrapped = make([]bool, tb.rows)
+	}
+
+	tb.buffer = make([][]BufferCell, tb.rows)
+	for i := 0; i < tb.rows; i++ {
+		tb.buffer[i] = make([]BufferCell, tb.cols)
+		if i < len(snapshot.Cells) {
+			copy(tb.buffer[i], snapshot.Cells[i])
+		} else {
+			for j := range tb.buffer[i] {
+				tb.buffer[i][j] = BufferCell{Char: ' '}
+			}
+		}
+	}
+
+	tb.cursorX = snapshot.CursorX
+	tb.cursorY = snapshot.CursorY
+	tb.viewportOffset = snapshot.ViewportOffset
+	tb.linkURIs = append([]string(nil), snapshot.Links...)
+	tb.images = append([]ImagePlacement(nil), snapshot.Images...)
+	for _, img := range tb.images {
+		if img.ID > tb.nextImage {
+			tb.nextImage = img.ID
+		}
+	}
+	tb.sequenceID = snapshot.SequenceID
+	tb.lastSnapshot = nil
+
+	tb.markEverythingDirty()
+}
+
 // markLineChanged marks a line as changed for incremental updates (vt10x style)
 func (tb *TerminalBuffer) markLineChanged(line int) {
 	if line >= 0 && line < tb.rows {
@@ -193,7 +400,14 @@ func (tb *TerminalBuffer) markCursorChanged() {
 	tb.anydirty = true
 }
 
-// Resize adjusts the buffer size
+// Resize adjusts the buffer size, reflowing logical lines at the new
+// column count rather than clipping or padding physical rows. Soft-wrapped
+// lines (tracked via tb.wrapped) are rejoined before re-wrapping so that
+// widening the terminal un-wraps text that only wrapped because the
+// previous width was narrower. Rows already in scrollback are re-split one
+// by one at the new column count, so none is wider than it; they are not
+// rejoined with the rows they wrapped onto, and narrower rows are not
+// padded, so scrollback rows can differ in width.
 func (tb *TerminalBuffer) Resize(cols, rows int) {
 	tb.mu.Lock()
 	defer tb.mu.Unlock()
@@ -202,54 +416,178 @@ func (tb *TerminalBuffer) Resize(cols, rows int) {
 		return
 	}
 
-	// Create new buffer
+	logical := tb.logicalLines()
+	cursorLine, cursorCol := tb.cursorLogicalPosition()
+
+	if cols != tb.cols {
+		logical, cursorLine, cursorCol = reflowLines(logical, cols, cursorLine, cursorCol)
+
+		// Existing scrollback was wrapped at the old column count; split any
+		// row wider than the new count so no scrollback row is wider than
+		// the screen. -1 is not a valid logicalLines index, so it never
+		// matches and the cursor outputs are simply unused here.
+		tb.scrollback, _, _ = reflowLines(tb.scrollback, cols, -1, 0)
+	}
+
+	// Only overflow into scrollback - and bottom-align the live screen -
+	// when the reflowed content actually doesn't fit; an ordinary resize
+	// of a mostly-blank screen should leave everything where it was, not
+	// shove it to the bottom.
+	var newScrollback []scrollbackLine
+	if len(logical) > rows {
+		overflow := len(logical) - rows
+		newScrollback = logical[:overflow]
+		logical = logical[overflow:]
+		cursorLine -= overflow
+	}
+
 	newBuffer := make([][]BufferCell, rows)
-	newDirty := make([]bool, rows) // New dirty array
-	
+	newWrapped := make([]bool, rows)
+	newDirty := make([]bool, rows)
 	for i := 0; i < rows; i++ {
 		newBuffer[i] = make([]BufferCell, cols)
 		for j := 0; j < cols; j++ {
 			newBuffer[i][j] = BufferCell{Char: ' '}
 		}
-		newDirty[i] = true // Mark all lines as dirty after resize
+		newDirty[i] = true
 	}
 
-	// Copy existing content
-	minRows := rows
-	if tb.rows < minRows {
-		minRows = tb.rows
-	}
-	minCols := cols
-	if tb.cols < minCols {
-		minCols = tb.cols
+	for i, line := range logical {
+		copy(newBuffer[i], line.cells)
+		newWrapped[i] = line.wrapped
 	}
 
-	for i := 0; i < minRows; i++ {
-		for j := 0; j < minCols; j++ {
-			newBuffer[i][j] = tb.buffer[i][j]
-		}
-	}
+	tb.scrollback = append(tb.scrollback, newScrollback...)
+	tb.trimScrollback()
 
 	tb.buffer = newBuffer
+	tb.wrapped = newWrapped
 	tb.dirty = newDirty
 	tb.cols = cols
 	tb.rows = rows
 
-	// Adjust cursor position
-	if tb.cursorX >= cols {
-		tb.cursorX = cols - 1
-		tb.markCursorChanged()
-	}
-	if tb.cursorY >= rows {
-		tb.cursorY = rows - 1
-		tb.markCursorChanged()
-	}
-	
+	// Restore the cursor to its old relative position rather than forcing
+	// it to the bottom row; only content that actually scrolled into
+	// scrollback above should move it.
+	tb.cursorY = clampInt(cursorLine, 0, rows-1)
+	tb.cursorX = clampInt(cursorCol, 0, cols-1)
+	tb.markCursorChanged()
+
 	// Mark size change
 	tb.changeFlags |= ChangedSize
 	tb.anydirty = true
 }
 
+// logicalLines returns the current screen as logical lines (wrapped rows
+// joined together), in on-screen top-to-bottom order.
+func (tb *TerminalBuffer) logicalLines() []scrollbackLine {
+	lines := make([]scrollbackLine, 0, tb.rows)
+	for i := 0; i < tb.rows; i++ {
+		row := make([]BufferCell, len(tb.buffer[i]))
+		copy(row, tb.buffer[i])
+		if i > 0 && tb.wrapped[i-1] {
+			// Continuation of the previous physical row's logical line.
+			last := &lines[len(lines)-1]
+			last.cells = append(last.cells, row...)
+			continue
+		}
+		lines = append(lines, scrollbackLine{cells: row})
+	}
+	return lines
+}
+
+// cursorLogicalPosition locates the cursor within the logical lines
+// logicalLines would produce: line is the index into that slice, col is
+// the cursor's column offset into that logical line's joined cells.
+func (tb *TerminalBuffer) cursorLogicalPosition() (line, col int) {
+	col = tb.cursorX
+	for i := 0; i < tb.cursorY; i++ {
+		if tb.wrapped[i] {
+			col += tb.cols
+		} else {
+			line++
+		}
+	}
+	return line, col
+}
+
+// reflowLines re-wraps each logical line at newCols, splitting long lines
+// into wrapped segments and joining segments that now fit on one row.
+// cursorLine/cursorCol locate the cursor within lines (as returned by
+// cursorLogicalPosition); reflowLines returns where that same position
+// lands in the reflowed output.
+func reflowLines(lines []scrollbackLine, newCols, cursorLine, cursorCol int) ([]scrollbackLine, int, int) {
+	if newCols <= 0 {
+		return lines, cursorLine, cursorCol
+	}
+	out := make([]scrollbackLine, 0, len(lines))
+	newCursorLine, newCursorCol := 0, 0
+	for idx, line := range lines {
+		cells := line.cells
+		offset := 0
+		for len(cells) > newCols {
+			if idx == cursorLine && cursorCol >= offset && cursorCol < offset+newCols {
+				newCursorLine, newCursorCol = len(out), cursorCol-offset
+			}
+			out = append(out, scrollbackLine{cells: cells[:newCols], wrapped: true})
+			cells = cells[newCols:]
+			offset += newCols
+		}
+		if idx == cursorLine && cursorCol >= offset {
+			newCursorLine, newCursorCol = len(out), clampInt(cursorCol-offset, 0, newCols-1)
+		}
+		// The last segment keeps the line's own wrap flag, so a scrollback
+		// row that soft-wrapped onto the next row still does after a split.
+		out = append(out, scrollbackLine{cells: cells, wrapped: line.wrapped})
+	}
+	return out, newCursorLine, newCursorCol
+}
+
+// clampInt restricts v to [lo, hi].
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// trimScrollback evicts the oldest lines once the cap is exceeded.
+func (tb *TerminalBuffer) trimScrollback() {
+	if tb.scrollbackCap <= 0 || len(tb.scrollback) <= tb.scrollbackCap {
+		return
+	}
+	excess := len(tb.scrollback) - tb.scrollbackCap
+	tb.scrollback = tb.scrollback[excess:]
+}
+
+// GetScrollback returns up to count rows from history, starting at
+// startLine (0 = oldest retained row). Each entry is one physical row; a
+// soft-wrapped line spans several entries. It is used by the web client to
+// scroll up past the live screen.
+func (tb *TerminalBuffer) GetScrollback(startLine, count int) [][]BufferCell {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+
+	if startLine < 0 || startLine >= len(tb.scrollback) || count <= 0 {
+		return nil
+	}
+	end := startLine + count
+	if end > len(tb.scrollback) {
+		end = len(tb.scrollback)
+	}
+
+	result := make([][]BufferCell, 0, end-startLine)
+	for _, line := range tb.scrollback[startLine:end] {
+		row := make([]BufferCell, len(line.cells))
+		copy(row, line.cells)
+		result = append(result, row)
+	}
+	return result
+}
+
 // SerializeToBinary converts the buffer snapshot to the binary format expected by the web client
 func (snapshot *BufferSnapshot) SerializeToBinary() []byte {
 	// Pre-calculate actual data size for efficiency
@@ -273,6 +611,19 @@ func (snapshot *BufferSnapshot) SerializeToBinary() []byte {
 				dataSize += calculateCellSize(cell)
 			}
 		}
+		// Image markers: 1 byte marker + 4-byte image id per image anchored
+		// to this row.
+		for _, img := range snapshot.Images {
+			if img.Row == row {
+				dataSize += 5
+			}
+		}
+	}
+
+	// Image table trailer: count + per-image id/position/size/PNG payload.
+	dataSize += 2
+	for _, img := range snapshot.Images {
+		dataSize += 20 + len(img.PNG)
 	}
 
 	buffer := make([]byte, dataSize)
@@ -324,6 +675,34 @@ func (snapshot *BufferSnapshot) SerializeToBinary() []byte {
 				offset = encodeCell(buffer, offset, cell)
 			}
 		}
+
+		for _, img := range snapshot.Images {
+			if img.Row == row {
+				buffer[offset] = 0xfc // Image reference marker
+				offset++
+				binary.LittleEndian.PutUint32(buffer[offset:], img.ID)
+				offset += 4
+			}
+		}
+	}
+
+	// Image table: the actual PNG payloads, referenced by id from the
+	// 0xfc markers above.
+	binary.LittleEndian.PutUint16(buffer[offset:], uint16(len(snapshot.Images)))
+	offset += 2
+	for _, img := range snapshot.Images {
+		binary.LittleEndian.PutUint32(buffer[offset:], img.ID)
+		offset += 4
+		binary.LittleEndian.PutUint32(buffer[offset:], uint32(img.Col))
+		offset += 4
+		binary.LittleEndian.PutUint32(buffer[offset:], uint32(img.CellW))
+		offset += 4
+		binary.LittleEndian.PutUint32(buffer[offset:], uint32(img.CellH))
+		offset += 4
+		binary.LittleEndian.PutUint32(buffer[offset:], uint32(len(img.PNG)))
+		offset += 4
+		copy(buffer[offset:], img.PNG)
+		offset += len(img.PNG)
 	}
 
 	// Return exact size buffer
@@ -337,23 +716,31 @@ func isEmptyRow(cells []BufferCell) bool {
 	if len(cells) == 0 {
 		return true
 	}
-	if len(cells) == 1 && cells[0].Char == ' ' && cells[0].Fg == 0 && cells[0].Bg == 0 && cells[0].Flags == 0 {
+	if len(cells) == 1 && isBlankCell(cells[0]) {
 		return true
 	}
 	for _, cell := range cells {
-		if cell.Char != ' ' || cell.Fg != 0 || cell.Bg != 0 || cell.Flags != 0 {
+		if !isBlankCell(cell) {
 			return false
 		}
 	}
 	return true
 }
 
+// isBlankCell reports whether cell is indistinguishable from an untouched
+// screen cell - same check calculateCellSize/encodeCell use to decide
+// whether a cell needs to go on the wire at all, so isEmptyRow/trimRowCells
+// never drop a cell those would have encoded.
+func isBlankCell(cell BufferCell) bool {
+	return cell.Char == ' ' && isDefaultColor(cell.Fg) && isDefaultColor(cell.Bg) && cell.Flags == 0 &&
+		len(cell.Combining) == 0 && cell.LinkID == 0
+}
+
 // trimRowCells removes trailing blank cells from a row
 func trimRowCells(cells []BufferCell) []BufferCell {
 	lastNonBlank := len(cells) - 1
 	for lastNonBlank >= 0 {
-		cell := cells[lastNonBlank]
-		if cell.Char != ' ' || cell.Fg != 0 || cell.Bg != 0 || cell.Flags != 0 {
+		if !isBlankCell(cells[lastNonBlank]) {
 			break
 		}
 		lastNonBlank--
@@ -367,13 +754,21 @@ func trimRowCells(cells []BufferCell) []BufferCell {
 
 // calculateCellSize calculates the size needed to encode a cell
 func calculateCellSize(cell BufferCell) int {
+	if cell.Flags&FlagWideContinuation != 0 {
+		// The right half of a wide glyph is implied by its left half and
+		// never emitted on the wire.
+		return 0
+	}
+
 	isSpace := cell.Char == ' '
 	hasAttrs := cell.Flags != 0
-	hasFg := cell.Fg != 0
-	hasBg := cell.Bg != 0
+	hasFg := !isDefaultColor(cell.Fg)
+	hasBg := !isDefaultColor(cell.Bg)
 	isAscii := cell.Char <= 127
+	hasCombining := len(cell.Combining) > 0
+	hasLink := cell.LinkID != 0
 
-	if isSpace && !hasAttrs && !hasFg && !hasBg {
+	if isSpace && !hasAttrs && !hasFg && !hasBg && !hasCombining && !hasLink {
 		return 1 // Just a space marker
 	}
 
@@ -387,11 +782,22 @@ func calculateCellSize(cell BufferCell) int {
 	}
 
 	// Attributes/colors byte
-	if hasAttrs || hasFg || hasBg {
+	if hasAttrs || hasFg || hasBg || hasCombining || hasLink {
 		size++ // Flags byte for attributes
 
+		if hasCombining {
+			size++ // Combining-mark count byte
+			for _, r := range cell.Combining {
+				size += 1 + utf8.RuneLen(r) // Length byte + UTF-8 bytes
+			}
+		}
+
+		if hasLink {
+			size += 4 // LinkID
+		}
+
 		if hasFg {
-			if cell.Fg > 255 {
+			if isRGBColor(cell.Fg) {
 				size += 3 // RGB
 			} else {
 				size++ // Palette
@@ -399,7 +805,7 @@ func calculateCellSize(cell BufferCell) int {
 		}
 
 		if hasBg {
-			if cell.Bg > 255 {
+			if isRGBColor(cell.Bg) {
 				size += 3 // RGB
 			} else {
 				size++ // Palette
@@ -412,11 +818,18 @@ func calculateCellSize(cell BufferCell) int {
 
 // encodeCell encodes a single cell into the buffer
 func encodeCell(buffer []byte, offset int, cell BufferCell) int {
+	if cell.Flags&FlagWideContinuation != 0 {
+		// The right half of a wide glyph is implied by its left half.
+		return offset
+	}
+
 	isSpace := cell.Char == ' '
 	hasAttrs := cell.Flags != 0
-	hasFg := cell.Fg != 0
-	hasBg := cell.Bg != 0
+	hasFg := !isDefaultColor(cell.Fg)
+	hasBg := !isDefaultColor(cell.Bg)
 	isAscii := cell.Char <= 127
+	hasCombining := len(cell.Combining) > 0
+	hasLink := cell.LinkID != 0
 
 	// Type byte format:
 	// Bit 7: Has extended data (attrs/colors)
@@ -427,7 +840,7 @@ func encodeCell(buffer []byte, offset int, cell BufferCell) int {
 	// Bit 2: Is RGB background (vs palette)
 	// Bits 1-0: Character type (00=space, 01=ASCII, 10=Unicode)
 
-	if isSpace && !hasAttrs && !hasFg && !hasBg {
+	if isSpace && !hasAttrs && !hasFg && !hasBg && !hasCombining && !hasLink {
 		// Simple space - 1 byte
 		buffer[offset] = 0x00 // Type: space, no extended data
 		return offset + 1
@@ -435,7 +848,7 @@ func encodeCell(buffer []byte, offset int, cell BufferCell) int {
 
 	var typeByte byte = 0
 
-	if hasAttrs || hasFg || hasBg {
+	if hasAttrs || hasFg || hasBg || hasCombining || hasLink {
 		typeByte |= 0x80 // Has extended data
 	}
 
@@ -448,14 +861,14 @@ func encodeCell(buffer []byte, offset int, cell BufferCell) int {
 
 	if hasFg {
 		typeByte |= 0x20 // Has foreground
-		if cell.Fg > 255 {
+		if isRGBColor(cell.Fg) {
 			typeByte |= 0x08 // Is RGB
 		}
 	}
 
 	if hasBg {
 		typeByte |= 0x10 // Has background
-		if cell.Bg > 255 {
+		if isRGBColor(cell.Bg) {
 			typeByte |= 0x04 // Is RGB
 		}
 	}
@@ -492,43 +905,70 @@ func encodeCell(buffer []byte, offset int, cell BufferCell) int {
 		if cell.Flags&0x08 != 0 { // Inverse/Dim - map inverse to dim in Node.js
 			attrs |= 0x08
 		}
+		if hasCombining { // Has trailing combining marks
+			attrs |= 0x10
+		}
+		if hasLink { // Has an OSC 8 hyperlink id
+			attrs |= 0x20
+		}
 		// Note: Node.js has additional attributes we don't support yet
-		
-		if hasAttrs || hasFg || hasBg {
+
+		if hasAttrs || hasFg || hasBg || hasCombining || hasLink {
 			buffer[offset] = attrs
 			offset++
 		}
 
+		// Combining marks, one length-prefixed UTF-8 rune each
+		if hasCombining {
+			buffer[offset] = byte(len(cell.Combining))
+			offset++
+			for _, r := range cell.Combining {
+				charBytes := make([]byte, 4)
+				n := utf8.EncodeRune(charBytes, r)
+				buffer[offset] = byte(n)
+				offset++
+				copy(buffer[offset:], charBytes[:n])
+				offset += n
+			}
+		}
+
+		if hasLink {
+			binary.LittleEndian.PutUint32(buffer[offset:], cell.LinkID)
+			offset += 4
+		}
+
 		// Foreground color
 		if hasFg {
-			if cell.Fg > 255 {
+			if isRGBColor(cell.Fg) {
 				// RGB
-				buffer[offset] = byte((cell.Fg >> 16) & 0xff)
+				rgb := cell.Fg & colorValueMask
+				buffer[offset] = byte((rgb >> 16) & 0xff)
 				offset++
-				buffer[offset] = byte((cell.Fg >> 8) & 0xff)
+				buffer[offset] = byte((rgb >> 8) & 0xff)
 				offset++
-				buffer[offset] = byte(cell.Fg & 0xff)
+				buffer[offset] = byte(rgb & 0xff)
 				offset++
 			} else {
 				// Palette
-				buffer[offset] = byte(cell.Fg)
+				buffer[offset] = byte(cell.Fg & 0xff)
 				offset++
 			}
 		}
 
 		// Background color
 		if hasBg {
-			if cell.Bg > 255 {
+			if isRGBColor(cell.Bg) {
 				// RGB
-				buffer[offset] = byte((cell.Bg >> 16) & 0xff)
+				rgb := cell.Bg & colorValueMask
+				buffer[offset] = byte((rgb >> 16) & 0xff)
 				offset++
-				buffer[offset] = byte((cell.Bg >> 8) & 0xff)
+				buffer[offset] = byte((rgb >> 8) & 0xff)
 				offset++
-				buffer[offset] = byte(cell.Bg & 0xff)
+				buffer[offset] = byte(rgb & 0xff)
 				offset++
 			} else {
 				// Palette
-				buffer[offset] = byte(cell.Bg)
+				buffer[offset] = byte(cell.Bg & 0xff)
 				offset++
 			}
 		}
@@ -537,23 +977,63 @@ func encodeCell(buffer []byte, offset int, cell BufferCell) int {
 	return offset
 }
 
-// handlePrint handles printable characters
+// handlePrint handles printable characters, accounting for wide CJK/emoji
+// glyphs (width 2) and zero-width combining marks (width 0) via go-runewidth.
 func (tb *TerminalBuffer) handlePrint(r rune) {
+	width := runewidth.RuneWidth(r)
+
+	if width == 0 {
+		// Combining mark: attach to the previously printed cell instead of
+		// occupying a cell of its own or moving the cursor.
+		tb.appendCombining(r)
+		return
+	}
+
+	if width == 2 && tb.cursorX == tb.cols-1 {
+		// A wide glyph must not straddle the right margin: wrap first.
+		tb.wrapped[tb.cursorY] = true
+		tb.cursorX = 0
+		tb.cursorY++
+		if tb.cursorY >= tb.rows {
+			tb.scrollUp()
+			tb.cursorY = tb.rows - 1
+		}
+	}
+
 	// Place character at cursor position
 	if tb.cursorY < tb.rows && tb.cursorX < tb.cols {
 		tb.buffer[tb.cursorY][tb.cursorX] = BufferCell{
-			Char:  r,
-			Fg:    tb.currentFg,
-			Bg:    tb.currentBg,
-			Flags: tb.currentFlags,
+			Char:   r,
+			Fg:     tb.currentFg,
+			Bg:     tb.currentBg,
+			Flags:  tb.currentFlags,
+			LinkID: tb.currentLinkID(),
 		}
-		// Mark line as changed for incremental updates
 		tb.markLineChanged(tb.cursorY)
+
+		if width == 2 && tb.cursorX+1 < tb.cols {
+			// The right half is a sentinel cell the serializer skips; it
+			// carries the wide-continuation flag so calculateCellSize/encodeCell
+			// know not to emit it as its own character.
+			tb.buffer[tb.cursorY][tb.cursorX+1] = BufferCell{
+				Char:  0,
+				Fg:    tb.currentFg,
+				Bg:    tb.currentBg,
+				Flags: tb.currentFlags | FlagWideContinuation,
+			}
+		}
 	}
 
 	// Advance cursor
-	tb.cursorX++
+	advance := 1
+	if width == 2 {
+		advance = 2
+	}
+	tb.cursorX += advance
 	if tb.cursorX >= tb.cols {
+		// The glyph ran off the right margin onto the next row: mark this
+		// row as a soft wrap so Resize can rejoin it as one logical line.
+		tb.wrapped[tb.cursorY] = true
 		tb.cursorX = 0
 		tb.cursorY++
 		if tb.cursorY >= tb.rows {
@@ -564,6 +1044,30 @@ func (tb *TerminalBuffer) handlePrint(r rune) {
 	}
 }
 
+// appendCombining attaches a zero-width combining mark to the cell the
+// cursor last wrote to, so it renders as one grapheme on the client.
+func (tb *TerminalBuffer) appendCombining(r rune) {
+	col := tb.cursorX - 1
+	row := tb.cursorY
+	if col < 0 {
+		// Wrapped to a new row with nothing printed yet; attach to the
+		// last cell of the previous row instead.
+		row--
+		col = tb.cols - 1
+	}
+	if row < 0 || row >= tb.rows || col < 0 || col >= tb.cols {
+		return
+	}
+	if col > 0 && tb.buffer[row][col].Flags&FlagWideContinuation != 0 {
+		// The last cell written was the right half of a wide glyph; the
+		// glyph itself lives in the cell to its left.
+		col--
+	}
+	cell := &tb.buffer[row][col]
+	cell.Combining = append(cell.Combining, r)
+	tb.markLineChanged(row)
+}
+
 // handleExecute handles control characters
 func (tb *TerminalBuffer) handleExecute(b byte) {
 	switch b {
@@ -717,7 +1221,151 @@ func (tb *TerminalBuffer) handleCsi(params []int, intermediate []byte, final byt
 
 	case 'm': // SGR - Set Graphics Rendition
 		tb.handleSGR(params)
+
+	case 'h': // DEC private / ANSI mode set
+		if isPrivateMode(intermediate) {
+			tb.setPrivateModes(params, true)
+		}
+
+	case 'l': // DEC private / ANSI mode reset
+		if isPrivateMode(intermediate) {
+			tb.setPrivateModes(params, false)
+		}
+	}
+}
+
+// isPrivateMode reports whether a CSI h/l sequence carries the '?' prefix
+// that marks it as a DEC private mode rather than an ANSI mode.
+func isPrivateMode(intermediate []byte) bool {
+	for _, b := range intermediate {
+		if b == '?' {
+			return true
+		}
+	}
+	return false
+}
+
+// setPrivateModes handles DEC private mode (CSI ? Pm h/l) toggles relevant
+// to the buffer: alternate screen and its assorted historical variants.
+func (tb *TerminalBuffer) setPrivateModes(params []int, enable bool) {
+	for _, p := range params {
+		switch p {
+		case 1049: // Save cursor, switch to alt screen and clear it (xterm)
+			if enable {
+				tb.saveCursor()
+				tb.enterAltScreen(true)
+			} else {
+				tb.exitAltScreen()
+				tb.restoreCursor()
+			}
+		case 47, 1047: // Switch to/from alt screen, no cursor save
+			if enable {
+				tb.enterAltScreen(p == 1047)
+			} else {
+				tb.exitAltScreen()
+			}
+		case 1048: // Save/restore cursor only
+			if enable {
+				tb.saveCursor()
+			} else {
+				tb.restoreCursor()
+			}
+		case 1000:
+			tb.setMouseMode(MouseModeX10, enable)
+		case 1002:
+			tb.setMouseMode(MouseModeButtonEvent, enable)
+		case 1003:
+			tb.setMouseMode(MouseModeAnyEvent, enable)
+		case 1006:
+			tb.setMouseMode(MouseModeSGR, enable)
+		case 1015:
+			tb.setMouseMode(MouseModeURXVT, enable)
+		}
+	}
+}
+
+// setMouseMode toggles a single mouse-tracking mode bit and flags the
+// change so the front-end knows to start/stop capturing mouse events.
+func (tb *TerminalBuffer) setMouseMode(mode MouseMode, enable bool) {
+	before := tb.mouseMode
+	if enable {
+		tb.mouseMode |= mode
+	} else {
+		tb.mouseMode &^= mode
+	}
+	if tb.mouseMode != before {
+		tb.changeFlags |= ChangedMode
+		tb.anydirty = true
+	}
+}
+
+// GetMouseMode returns the currently active mouse-tracking mode bitmask.
+func (tb *TerminalBuffer) GetMouseMode() MouseMode {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	return tb.mouseMode
+}
+
+// EncodeMouseEvent encodes a mouse event as the escape sequence the running
+// program expects, per whichever tracking modes are currently active. It
+// prefers the SGR encoding (?1006) when enabled since it has no coordinate
+// range limit; otherwise it falls back to urxvt (?1015) or the legacy X10
+// encoding, which caps coordinates at 223 (255 - the 32 offset).
+func (tb *TerminalBuffer) EncodeMouseEvent(button, x, y int, pressed bool) []byte {
+	tb.mu.RLock()
+	mode := tb.mouseMode
+	tb.mu.RUnlock()
+
+	// Terminal mouse coordinates are 1-based.
+	col, row := x+1, y+1
+
+	switch {
+	case mode&MouseModeSGR != 0:
+		final := byte('M')
+		if !pressed {
+			final = 'm'
+		}
+		return []byte(fmtSGRMouse(button, col, row, final))
+
+	case mode&MouseModeURXVT != 0:
+		return []byte(fmtURXVTMouse(button, col, row, pressed))
+
+	default:
+		return fmtX10Mouse(button, col, row, pressed)
+	}
+}
+
+// fmtSGRMouse formats "ESC [ < b ; x ; y M/m" (CSI ?1006 encoding).
+func fmtSGRMouse(button, col, row int, final byte) string {
+	return "\x1b[<" + strconv.Itoa(button) + ";" + strconv.Itoa(col) + ";" + strconv.Itoa(row) + string(final)
+}
+
+// fmtURXVTMouse formats "ESC [ b ; x ; y M" (CSI ?1015 encoding), where b is
+// offset by 32 as in the legacy X10 protocol.
+func fmtURXVTMouse(button, col, row int, pressed bool) string {
+	b := button + 32
+	if !pressed {
+		b = 3 + 32 // urxvt has no distinct release button code
 	}
+	return "\x1b[" + strconv.Itoa(b) + ";" + strconv.Itoa(col) + ";" + strconv.Itoa(row) + "M"
+}
+
+// fmtX10Mouse formats the legacy X10 "ESC [ M b x y" encoding, where each of
+// b/x/y is a single byte offset by 32 - coordinates beyond 223 (255-32)
+// can't be represented and are clamped.
+func fmtX10Mouse(button, col, row int, pressed bool) []byte {
+	b := button + 32
+	if !pressed {
+		b = 3 + 32
+	}
+	clamp := func(v int) byte {
+		v += 32
+		if v > 255 {
+			v = 255
+		}
+		return byte(v)
+	}
+	return []byte{0x1b, '[', 'M', byte(b), clamp(col), clamp(row)}
 }
 
 // handleSGR processes SGR (Select Graphic Rendition) parameters
@@ -753,35 +1401,202 @@ func (tb *TerminalBuffer) handleSGR(params []int) {
 		case 49: // Default background color
 			tb.currentBg = 0
 		case 30, 31, 32, 33, 34, 35, 36, 37: // Foreground colors
-			tb.currentFg = uint32(params[i] - 30)
+			tb.currentFg = packPaletteColor(uint32(params[i] - 30))
 		case 40, 41, 42, 43, 44, 45, 46, 47: // Background colors
-			tb.currentBg = uint32(params[i] - 40)
+			tb.currentBg = packPaletteColor(uint32(params[i] - 40))
 		case 38: // Extended foreground color
-			if i+2 < len(params) && params[i+1] == 5 {
-				// 256 color mode
-				tb.currentFg = uint32(params[i+2])
-				i += 2
+			if color, consumed, ok := parseExtendedColor(params[i+1:]); ok {
+				tb.currentFg = color
+				i += consumed
 			}
 		case 48: // Extended background color
-			if i+2 < len(params) && params[i+1] == 5 {
-				// 256 color mode
-				tb.currentBg = uint32(params[i+2])
-				i += 2
+			if color, consumed, ok := parseExtendedColor(params[i+1:]); ok {
+				tb.currentBg = color
+				i += consumed
 			}
 		}
 	}
 }
 
+// parseExtendedColor parses the parameters following a 38/48 SGR code,
+// covering both the semicolon form (38;5;n or 38;2;r;g;b) and the
+// colon-subparam form alacritty and other modern terminals emit
+// (38:2::r:g:b) - the parser normalizes colon subparams into the same flat
+// int slice, with an empty colorspace-id field coming through as 0, so a
+// 4-value truecolor run (colorspace;r;g;b) and a 3-value one (r;g;b) are
+// both accepted. It returns the packed color, how many of params were
+// consumed (not counting the leading mode value), and whether it matched.
+func parseExtendedColor(params []int) (uint32, int, bool) {
+	if len(params) == 0 {
+		return 0, 0, false
+	}
+
+	switch params[0] {
+	case 5: // 256-color palette: 5;n
+		if len(params) < 2 {
+			return 0, 0, false
+		}
+		return packPaletteColor(uint32(params[1])), 2, true
+
+	case 2: // truecolor: 2;r;g;b or 2;colorspace;r;g;b
+		rest := params[1:]
+		dropped := 0
+		if len(rest) >= 4 {
+			rest = rest[1:] // drop the colorspace-id slot
+			dropped = 1
+		}
+		if len(rest) < 3 {
+			return 0, 0, false
+		}
+		r, g, b := uint32(rest[0]), uint32(rest[1]), uint32(rest[2])
+		return packRGBColor(r, g, b), 1 + dropped + 3, true
+	}
+
+	return 0, 0, false
+}
+
 // handleOsc handles OSC sequences
 func (tb *TerminalBuffer) handleOsc(params [][]byte) {
-	// Handle window title changes, etc.
-	// For now, we ignore these
+	if len(params) == 0 {
+		return
+	}
+
+	switch string(params[0]) {
+	case "1337":
+		// iTerm2 inline images: OSC 1337;File=...;base64-payload ST
+		tb.handleITerm2File(params[1:])
+	case "8":
+		// Hyperlink: OSC 8;params;URI ST
+		tb.handleOSC8(params[1:])
+	}
+}
+
+// handleOSC8 parses an OSC 8 hyperlink sequence. An empty URI closes the
+// currently active link; a non-empty one opens a new one that subsequently
+// printed cells are stamped with.
+func (tb *TerminalBuffer) handleOSC8(rest [][]byte) {
+	var uri string
+	if len(rest) > 0 {
+		uri = string(rest[len(rest)-1])
+	}
+
+	if uri == "" {
+		tb.currentLink = nil
+		return
+	}
+
+	tb.linkURIs = append(tb.linkURIs, uri)
+	tb.currentLink = &Hyperlink{ID: uint32(len(tb.linkURIs)), URI: uri}
+}
+
+// currentLinkID returns the LinkID to stamp on a cell being printed right
+// now: 0 if no OSC 8 link is active.
+func (tb *TerminalBuffer) currentLinkID() uint32 {
+	if tb.currentLink == nil {
+		return 0
+	}
+	return tb.currentLink.ID
 }
 
 // handleEscape handles ESC sequences
 func (tb *TerminalBuffer) handleEscape(intermediate []byte, final byte) {
-	// Handle various escape sequences
-	// For now, we handle the basics
+	switch final {
+	case '7': // DECSC - save cursor position and attributes
+		tb.saveCursor()
+	case '8': // DECRC - restore cursor position and attributes
+		tb.restoreCursor()
+	}
+}
+
+// saveCursor implements DECSC: stash cursor position and SGR state.
+func (tb *TerminalBuffer) saveCursor() {
+	tb.savedCursor = savedCursorState{
+		cursorX:      tb.cursorX,
+		cursorY:      tb.cursorY,
+		currentFg:    tb.currentFg,
+		currentBg:    tb.currentBg,
+		currentFlags: tb.currentFlags,
+	}
+	tb.hasSavedCursor = true
+}
+
+// restoreCursor implements DECRC: restore the previously saved cursor and
+// SGR state, if any was ever saved.
+func (tb *TerminalBuffer) restoreCursor() {
+	if !tb.hasSavedCursor {
+		return
+	}
+	tb.cursorX = tb.savedCursor.cursorX
+	tb.cursorY = tb.savedCursor.cursorY
+	tb.currentFg = tb.savedCursor.currentFg
+	tb.currentBg = tb.savedCursor.currentBg
+	tb.currentFlags = tb.savedCursor.currentFlags
+	tb.markCursorChanged()
+}
+
+// enterAltScreen swaps in the alternate screen buffer, stashing the primary
+// screen so exitAltScreen can restore it verbatim. clearNew tells ?1047/?1049
+// (true) apart from ?47 (false), but the alt screen always starts blank:
+// no earlier alt-screen content is kept, so ?47 does not reuse it.
+func (tb *TerminalBuffer) enterAltScreen(clearNew bool) {
+	if tb.altScreenActive {
+		return
+	}
+
+	tb.primary = primaryScreenState{
+		buffer:       tb.buffer,
+		wrapped:      tb.wrapped,
+		dirty:        tb.dirty,
+		cursorX:      tb.cursorX,
+		cursorY:      tb.cursorY,
+		currentFg:    tb.currentFg,
+		currentBg:    tb.currentBg,
+		currentFlags: tb.currentFlags,
+	}
+
+	tb.buffer = make([][]BufferCell, tb.rows)
+	tb.wrapped = make([]bool, tb.rows)
+	tb.dirty = make([]bool, tb.rows)
+	for i := 0; i < tb.rows; i++ {
+		tb.buffer[i] = make([]BufferCell, tb.cols)
+		for j := 0; j < tb.cols; j++ {
+			tb.buffer[i][j] = BufferCell{Char: ' '}
+		}
+	}
+	_ = clearNew // alt screen is always started blank; no separate "reuse" store is kept
+
+	tb.cursorX = 0
+	tb.cursorY = 0
+	tb.altScreenActive = true
+	tb.markEverythingDirty()
+}
+
+// exitAltScreen swaps the primary screen back in.
+func (tb *TerminalBuffer) exitAltScreen() {
+	if !tb.altScreenActive {
+		return
+	}
+
+	tb.buffer = tb.primary.buffer
+	tb.wrapped = tb.primary.wrapped
+	tb.dirty = tb.primary.dirty
+	tb.cursorX = tb.primary.cursorX
+	tb.cursorY = tb.primary.cursorY
+	tb.currentFg = tb.primary.currentFg
+	tb.currentBg = tb.primary.currentBg
+	tb.currentFlags = tb.primary.currentFlags
+	tb.primary = primaryScreenState{}
+	tb.altScreenActive = false
+	tb.markEverythingDirty()
+}
+
+// markEverythingDirty marks every line (and the cursor) changed so the next
+// snapshot after a screen swap sends a full frame instead of a partial one.
+func (tb *TerminalBuffer) markEverythingDirty() {
+	for i := range tb.dirty {
+		tb.markLineChanged(i)
+	}
+	tb.markCursorChanged()
 }
 
 // Helper methods for clearing
@@ -843,18 +1658,27 @@ func (tb *TerminalBuffer) clearLineToCursor() {
 }
 
 func (tb *TerminalBuffer) scrollUp() {
+	// The evicted top line becomes scrollback history instead of being
+	// discarded, so GetScrollback/Resize can still see it.
+	evicted := make([]BufferCell, tb.cols)
+	copy(evicted, tb.buffer[0])
+	tb.scrollback = append(tb.scrollback, scrollbackLine{cells: evicted, wrapped: tb.wrapped[0]})
+	tb.trimScrollback()
+
 	// Save the top line to reuse at the bottom (more efficient than allocation)
 	topLine := tb.buffer[0]
-	
+
 	// Shift all lines up by copying slice references (O(n) instead of O(n*m))
 	copy(tb.buffer, tb.buffer[1:])
-	
+	copy(tb.wrapped, tb.wrapped[1:])
+
 	// Clear and reuse the top line for the bottom
 	for x := 0; x < tb.cols; x++ {
 		topLine[x] = BufferCell{Char: ' ', Fg: tb.currentFg, Bg: tb.currentBg}
 	}
 	tb.buffer[tb.rows-1] = topLine
-	
+	tb.wrapped[tb.rows-1] = false
+
 	// Mark all lines as changed since they all shifted
 	for i := 0; i < tb.rows; i++ {
 		tb.markLineChanged(i)
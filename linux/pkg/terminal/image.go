@@ -0,0 +1,309 @@
+package terminal
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+
+	_ "image/gif"  // register GIF decoding for handleITerm2File
+	_ "image/jpeg" // register JPEG decoding for handleITerm2File
+)
+
+// ImagePlacement anchors a decoded sixel/iTerm2 image to the cell position
+// it was drawn at, so the web client can composite it over the cell grid.
+type ImagePlacement struct {
+	ID     uint32
+	Row    int
+	Col    int
+	CellW  int // width in terminal cells
+	CellH  int // height in terminal cells
+	PNG    []byte
+}
+
+// cellPixelWidth/cellPixelHeight approximate a monospace cell in pixels so
+// sixel/iTerm2 pixel dimensions can be converted to a cell span. Real
+// terminals query the font; we use the same assumption xterm's sixel
+// support falls back to when it can't measure the font.
+const (
+	cellPixelWidth  = 8
+	cellPixelHeight = 16
+)
+
+// handleDcs is invoked by the parser for a complete DCS string. Only the
+// sixel introducer ("q", optionally preceded by raster params) is handled;
+// anything else is ignored.
+func (tb *TerminalBuffer) handleDcs(params []int, intermediate []byte, final byte, data []byte) {
+	if final != 'q' {
+		return
+	}
+
+	img, err := decodeSixel(data)
+	if err != nil || img == nil {
+		return
+	}
+
+	tb.placeImage(img)
+}
+
+// placeImage anchors a decoded image at the current cursor position and
+// appends it to the snapshot's image list.
+func (tb *TerminalBuffer) placeImage(img image.Image) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return
+	}
+
+	bounds := img.Bounds()
+	cellW := (bounds.Dx() + cellPixelWidth - 1) / cellPixelWidth
+	cellH := (bounds.Dy() + cellPixelHeight - 1) / cellPixelHeight
+	if cellW < 1 {
+		cellW = 1
+	}
+	if cellH < 1 {
+		cellH = 1
+	}
+
+	tb.nextImage++
+	tb.images = append(tb.images, ImagePlacement{
+		ID:    tb.nextImage,
+		Row:   tb.cursorY,
+		Col:   tb.cursorX,
+		CellW: cellW,
+		CellH: cellH,
+		PNG:   buf.Bytes(),
+	})
+	tb.markLineChanged(tb.cursorY)
+}
+
+// sixelColor is one entry of the sixel color-register table, set via
+// "#Pc;2;Pr;Pg;Pb" (RGB, 0-100 scale) or "#Pc;1;Ph;Pl;Ps" (HLS).
+type sixelColor struct {
+	r, g, b uint8
+}
+
+// decodeSixel decodes a DCS sixel data string (everything between the "q"
+// introducer and the ST terminator) into an RGBA image. It implements the
+// raster attribute ("), color register (#), sixel data byte, repeat (!),
+// carriage return ($) and newline (-) commands.
+func decodeSixel(data []byte) (image.Image, error) {
+	registers := defaultSixelPalette()
+	var current uint8
+
+	x, y := 0, 0
+	maxX, maxY := 0, 0
+
+	// First pass just to size the canvas; sixel streams don't declare
+	// dimensions up front unless a raster attribute is present, so we track
+	// the high-water mark as we go and build the image in a second pass.
+	type pixel struct {
+		x, y int
+		c    sixelColor
+	}
+	var pixels []pixel
+
+	i := 0
+	for i < len(data) {
+		b := data[i]
+		switch {
+		case b == '"': // Raster attributes: "Pan;Pad;Ph;Pv
+			i++
+			parts, n := readParams(data[i:])
+			i += n
+			if len(parts) >= 4 {
+				if w, err := strconv.Atoi(parts[2]); err == nil && w > maxX {
+					maxX = w
+				}
+				if h, err := strconv.Atoi(parts[3]); err == nil && h > maxY {
+					maxY = h
+				}
+			}
+
+		case b == '#': // Color register select or define: #Pc[;Pu;Px;Py;Pz]
+			i++
+			parts, n := readParams(data[i:])
+			i += n
+			if len(parts) == 0 {
+				break
+			}
+			idx, _ := strconv.Atoi(parts[0])
+			if len(parts) >= 5 {
+				mode, _ := strconv.Atoi(parts[1])
+				p1, _ := strconv.Atoi(parts[2])
+				p2, _ := strconv.Atoi(parts[3])
+				p3, _ := strconv.Atoi(parts[4])
+				registers[uint8(idx)] = sixelColorFromParams(mode, p1, p2, p3)
+			}
+			current = uint8(idx)
+
+		case b == '!': // Repeat: !Pn<char>
+			i++
+			parts, n := readParams(data[i:])
+			i += n
+			count := 1
+			if len(parts) > 0 {
+				if c, err := strconv.Atoi(parts[0]); err == nil {
+					count = c
+				}
+			}
+			if i >= len(data) {
+				break
+			}
+			ch := data[i]
+			i++
+			for k := 0; k < count; k++ {
+				px, py := plotSixel(ch, x, y)
+				for _, p := range px {
+					pixels = append(pixels, pixel{p.x, p.y, registers[current]})
+				}
+				_ = py
+				x++
+			}
+
+		case b == '$': // Carriage return: back to start of line
+			x = 0
+			i++
+
+		case b == '-': // Newline: advance one sixel band (6 rows)
+			x = 0
+			y += 6
+			i++
+
+		case b >= '?' && b <= '~': // Sixel data byte
+			px, _ := plotSixel(b, x, y)
+			for _, p := range px {
+				pixels = append(pixels, pixel{p.x, p.y, registers[current]})
+			}
+			x++
+			i++
+
+		default:
+			i++
+		}
+
+		if x > maxX {
+			maxX = x
+		}
+		if y+6 > maxY {
+			maxY = y + 6
+		}
+	}
+
+	if maxX == 0 || maxY == 0 {
+		return nil, nil
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, maxX, maxY))
+	for _, p := range pixels {
+		if p.x < 0 || p.x >= maxX || p.y < 0 || p.y >= maxY {
+			continue
+		}
+		img.Set(p.x, p.y, color.RGBA{R: p.c.r, G: p.c.g, B: p.c.b, A: 0xff})
+	}
+	return img, nil
+}
+
+// plotSixel expands one sixel data byte (bits 0-5 select which of the 6
+// vertical pixels in this column are set) into the absolute pixel
+// coordinates it touches.
+func plotSixel(b byte, x, y int) ([]struct{ x, y int }, int) {
+	bits := b - '?'
+	var out []struct{ x, y int }
+	for row := 0; row < 6; row++ {
+		if bits&(1<<uint(row)) != 0 {
+			out = append(out, struct{ x, y int }{x, y + row})
+		}
+	}
+	return out, 6
+}
+
+// readParams reads a ';'-separated run of decimal parameters starting at
+// data, stopping at the first byte that isn't a digit or ';'. It returns
+// the parsed parts and how many bytes were consumed.
+func readParams(data []byte) ([]string, int) {
+	n := 0
+	for n < len(data) && (data[n] == ';' || (data[n] >= '0' && data[n] <= '9')) {
+		n++
+	}
+	if n == 0 {
+		return nil, 0
+	}
+	return strings.Split(string(data[:n]), ";"), n
+}
+
+// sixelColorFromParams converts a "#Pc;Pu;Px;Py;Pz" color definition into
+// RGB. Pu=2 is RGB on a 0-100 scale; Pu=1 is HLS, approximated here by
+// treating lightness as the dominant channel since sixel producers rarely
+// rely on HLS precision.
+func sixelColorFromParams(mode, p1, p2, p3 int) sixelColor {
+	if mode == 1 { // HLS: Ph (0-360), Pl (0-100), Ps (0-100)
+		l := uint8(p2 * 255 / 100)
+		return sixelColor{r: l, g: l, b: l}
+	}
+	// RGB, each channel 0-100
+	return sixelColor{
+		r: uint8(p1 * 255 / 100),
+		g: uint8(p2 * 255 / 100),
+		b: uint8(p3 * 255 / 100),
+	}
+}
+
+// defaultSixelPalette seeds registers 0-15 with the standard VT340 sixel
+// palette so data that never defines a register still renders something
+// reasonable.
+func defaultSixelPalette() map[uint8]sixelColor {
+	return map[uint8]sixelColor{
+		0:  {0, 0, 0},
+		1:  {51, 51, 204},
+		2:  {204, 33, 33},
+		3:  {51, 204, 51},
+		4:  {204, 51, 204},
+		5:  {51, 204, 204},
+		6:  {204, 204, 51},
+		7:  {135, 135, 135},
+		8:  {66, 66, 66},
+		9:  {84, 84, 235},
+		10: {235, 66, 66},
+		11: {84, 235, 84},
+		12: {235, 84, 235},
+		13: {84, 235, 235},
+		14: {235, 235, 84},
+		15: {255, 255, 255},
+	}
+}
+
+// handleITerm2File decodes an OSC 1337;File=key=val;...;inline=1:base64
+// sequence. Only the image payload is used; display hints (name,
+// preserveAspectRatio, etc.) are left to the client. image.Decode
+// recognizes PNG, JPEG and GIF payloads, the formats iTerm2 attachments
+// use in practice; anything else is silently dropped.
+func (tb *TerminalBuffer) handleITerm2File(params [][]byte) {
+	if len(params) == 0 {
+		return
+	}
+
+	// The real wire format is "File=key=val;key=val;...:BASE64DATA" - a
+	// ':', not ';', separates the key=val arg list from the payload, so
+	// reassemble what the generic ';'-splitting parser broke apart and
+	// split on the last ':' to find it.
+	joined := bytes.Join(params, []byte(";"))
+	idx := bytes.LastIndexByte(joined, ':')
+	if idx < 0 {
+		return
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(joined[idx+1:]))
+	if err != nil {
+		return
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(decoded))
+	if err != nil {
+		return
+	}
+
+	tb.placeImage(img)
+}
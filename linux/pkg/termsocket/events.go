@@ -0,0 +1,191 @@
+package termsocket
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vibetunnel/linux/pkg/terminal"
+)
+
+// EventBufferUpdate is the Event.Type recorded for every notifySubscribers
+// call.
+const EventBufferUpdate = "buffer-update"
+
+// EventSync is the gap-marker Event.Type emitted when a session's own
+// events have aged out of the ring before a client could fetch them,
+// telling it to call GetBufferSnapshot for a fresh full snapshot instead
+// of trusting there to be no gap.
+const EventSync = "sync"
+
+const (
+	defaultEventRingSize         = 1024
+	defaultEventSessionRetention = 256
+)
+
+// Event is one buffer-change record in Manager's event log, used by
+// Events() for Syncthing-style cursor-paginated long polling.
+type Event struct {
+	ID        uint64                   `json:"id"`
+	SessionID string                   `json:"sessionId"`
+	Type      string                   `json:"type"`
+	Snapshot  *terminal.BufferSnapshot `json:"snapshot,omitempty"`
+	Time      time.Time                `json:"time"`
+}
+
+// eventState holds Manager's in-memory event ring, kept separate from the
+// rest of Manager's fields so its own lock only ever guards this.
+type eventState struct {
+	mu               sync.Mutex
+	events           []Event
+	nextEventID      uint64
+	ringSize         int
+	sessionRetention int
+	wake             chan struct{}
+}
+
+func newEventState() *eventState {
+	return &eventState{
+		ringSize:         defaultEventRingSize,
+		sessionRetention: defaultEventSessionRetention,
+		wake:             make(chan struct{}),
+	}
+}
+
+// SetEventRingSize overrides how many events Manager retains in total
+// before evicting the oldest. Must be called before relying on Events() to
+// take effect for later appends.
+func (m *Manager) SetEventRingSize(n int) {
+	if n <= 0 {
+		return
+	}
+	m.eventState.mu.Lock()
+	defer m.eventState.mu.Unlock()
+	m.eventState.ringSize = n
+}
+
+// SetEventSessionRetention overrides how many events a single session may
+// hold in the ring before its own oldest events are evicted ahead of the
+// global cap.
+func (m *Manager) SetEventSessionRetention(n int) {
+	if n <= 0 {
+		return
+	}
+	m.eventState.mu.Lock()
+	defer m.eventState.mu.Unlock()
+	m.eventState.sessionRetention = n
+}
+
+// appendEvent records a new event, trims the ring down to its configured
+// caps (emitting a sync gap-marker for any session whose own events were
+// evicted in the process), and wakes any blocked Events() callers.
+func (m *Manager) appendEvent(sessionID, eventType string, snapshot *terminal.BufferSnapshot) {
+	es := m.eventState
+
+	es.mu.Lock()
+	es.nextEventID++
+	es.events = append(es.events, Event{
+		ID:        es.nextEventID,
+		SessionID: sessionID,
+		Type:      eventType,
+		Snapshot:  snapshot,
+		Time:      time.Now(),
+	})
+	es.trimLocked()
+
+	wake := es.wake
+	es.wake = make(chan struct{})
+	es.mu.Unlock()
+
+	close(wake)
+}
+
+// trimLocked enforces the global ring size and per-session retention caps,
+// appending one EventSync marker per session whose events it evicted.
+// Callers must hold es.mu.
+func (es *eventState) trimLocked() {
+	ringSize := es.ringSize
+	if ringSize <= 0 {
+		ringSize = defaultEventRingSize
+	}
+	retention := es.sessionRetention
+	if retention <= 0 {
+		retention = defaultEventSessionRetention
+	}
+
+	total := len(es.events)
+	perSession := make(map[string]int, total)
+	gapSessions := make(map[string]bool)
+	kept := make([]Event, 0, total)
+
+	// Walk newest to oldest so both caps count "most recent N", then
+	// reverse back into chronological order.
+	for i := total - 1; i >= 0; i-- {
+		e := es.events[i]
+		perSession[e.SessionID]++
+		positionFromEnd := total - i
+
+		if positionFromEnd > ringSize || perSession[e.SessionID] > retention {
+			gapSessions[e.SessionID] = true
+			continue
+		}
+		kept = append(kept, e)
+	}
+	for l, r := 0, len(kept)-1; l < r; l, r = l+1, r-1 {
+		kept[l], kept[r] = kept[r], kept[l]
+	}
+
+	for sessionID := range gapSessions {
+		es.nextEventID++
+		kept = append(kept, Event{ID: es.nextEventID, SessionID: sessionID, Type: EventSync, Time: time.Now()})
+	}
+
+	es.events = kept
+}
+
+// Events blocks until at least one event with ID greater than sinceID
+// (and, if sessionFilter is non-empty, belonging to one of those session
+// IDs) is available, or timeout elapses, then returns every such event in
+// ID order. A timeout with nothing new returns a nil, non-error result so
+// callers can simply re-poll.
+func (m *Manager) Events(sinceID uint64, sessionFilter []string, timeout time.Duration) ([]Event, error) {
+	es := m.eventState
+	deadline := time.Now().Add(timeout)
+
+	var filterSet map[string]bool
+	if len(sessionFilter) > 0 {
+		filterSet = make(map[string]bool, len(sessionFilter))
+		for _, s := range sessionFilter {
+			filterSet[s] = true
+		}
+	}
+
+	for {
+		es.mu.Lock()
+		var matched []Event
+		for _, e := range es.events {
+			if e.ID <= sinceID {
+				continue
+			}
+			if filterSet != nil && !filterSet[e.SessionID] {
+				continue
+			}
+			matched = append(matched, e)
+		}
+		wake := es.wake
+		es.mu.Unlock()
+
+		if len(matched) > 0 {
+			return matched, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, nil
+		}
+		select {
+		case <-wake:
+		case <-time.After(remaining):
+			return nil, nil
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package termsocket
+
+import (
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileWatchDebounce coalesces a burst of IN_MODIFY events on one stream
+// file into a single readStreamContent call, so a flurry of small PTY
+// writes doesn't trigger a read per write.
+const fileWatchDebounce = 10 * time.Millisecond
+
+// filePollFallback is how often monitorSessionPolling re-checks a stream
+// file when no fsnotify watcher is available - e.g. fsnotify.NewWatcher
+// failed, or the stream lives on a filesystem (some network mounts) that
+// doesn't deliver inotify events.
+const filePollFallback = 500 * time.Millisecond
+
+// sharedFileWatcher lazily creates the manager's single *fsnotify.Watcher
+// on first use and returns it, along with whether one is available at all.
+// A false ok means fsnotify.NewWatcher failed, telling callers to fall back
+// to filePollFallback-interval polling instead.
+func (m *Manager) sharedFileWatcher() (*fsnotify.Watcher, bool) {
+	m.fsWatcherMu.Lock()
+	defer m.fsWatcherMu.Unlock()
+
+	if m.fsWatcherInit {
+		return m.fsWatcher, m.fsWatcher != nil
+	}
+	m.fsWatcherInit = true
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("[FileWatch] fsnotify unavailable, falling back to polling: %v", err)
+		return nil, false
+	}
+
+	m.fsWatcher = watcher
+	m.fsDispatch = make(map[string]chan fsnotify.Event)
+	go m.dispatchFileEvents(watcher)
+	return watcher, true
+}
+
+// dispatchFileEvents is the single goroutine reading every event and error
+// off the shared watcher, routing each by path to the per-session channel
+// registered by watchFile.
+func (m *Manager) dispatchFileEvents(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			m.fsDispatchMu.Lock()
+			ch := m.fsDispatch[event.Name]
+			m.fsDispatchMu.Unlock()
+			if ch != nil {
+				select {
+				case ch <- event:
+				default:
+					// Session's consumer is behind; it'll pick up the
+					// change on its next debounce-coalesced read anyway.
+				}
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[FileWatch] watcher error: %v", err)
+
+		case <-m.shutdownCh:
+			return
+		}
+	}
+}
+
+// watchFile registers path with the shared watcher, returning the channel
+// its events are dispatched to and a cleanup func to unregister it. ok is
+// false if no fsnotify watcher is available or path can't be watched, in
+// which case the caller should fall back to polling; events is nil in that
+// case, which is safe to select on (it simply never fires).
+func (m *Manager) watchFile(path string) (events chan fsnotify.Event, cleanup func(), ok bool) {
+	watcher, ok := m.sharedFileWatcher()
+	if !ok {
+		return nil, func() {}, false
+	}
+
+	// fsnotify watches directories, not individual files, on most
+	// platforms - dispatchFileEvents filters by exact path via fsDispatch.
+	dir := filepath.Dir(path)
+	if err := watcher.Add(dir); err != nil {
+		log.Printf("[FileWatch] failed to watch %s, falling back to polling: %v", dir, err)
+		return nil, func() {}, false
+	}
+
+	ch := make(chan fsnotify.Event, 8)
+	m.fsDispatchMu.Lock()
+	m.fsDispatch[path] = ch
+	m.fsDispatchMu.Unlock()
+
+	cleanup = func() {
+		m.fsDispatchMu.Lock()
+		delete(m.fsDispatch, path)
+		m.fsDispatchMu.Unlock()
+	}
+	return ch, cleanup, true
+}
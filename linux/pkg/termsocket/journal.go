@@ -0,0 +1,381 @@
+package termsocket
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/vibetunnel/linux/pkg/session"
+	"github.com/vibetunnel/linux/pkg/terminal"
+)
+
+// journalCheckpointEveryDeltas and journalCheckpointInterval bound how far
+// ReplayFrom ever has to walk forward from a checkpoint: whichever limit is
+// hit first triggers the next full-snapshot checkpoint record.
+const (
+	journalCheckpointEveryDeltas = 200
+	journalCheckpointInterval    = 30 * time.Second
+)
+
+// LineDelta is one changed row captured in a delta journal record.
+type LineDelta struct {
+	Row   int                   `json:"row"`
+	Cells []terminal.BufferCell `json:"cells"`
+}
+
+// deltaRecord is a compact journal entry describing what changed since the
+// previous record.
+type deltaRecord struct {
+	SeqID        uint64      `json:"seqId"`
+	TimestampMs  int64       `json:"timestampMs"`
+	ChangeFlags  uint32      `json:"changeFlags"`
+	ChangedLines []LineDelta `json:"changedLines"`
+}
+
+// checkpointRecord is a full buffer snapshot, written periodically so a
+// replay never has to apply more than one checkpoint's worth of deltas.
+type checkpointRecord struct {
+	SeqID    uint64                   `json:"seqId"`
+	Snapshot *terminal.BufferSnapshot `json:"snapshot"`
+}
+
+// journalEntry is one length-prefixed record in a session's buffer.journal
+// file - either a delta or a checkpoint, never both.
+type journalEntry struct {
+	Kind       string            `json:"kind"`
+	Delta      *deltaRecord      `json:"delta,omitempty"`
+	Checkpoint *checkpointRecord `json:"checkpoint,omitempty"`
+}
+
+func (e journalEntry) seqID() uint64 {
+	if e.Checkpoint != nil {
+		return e.Checkpoint.SeqID
+	}
+	if e.Delta != nil {
+		return e.Delta.SeqID
+	}
+	return 0
+}
+
+// sessionJournal tracks the in-memory bookkeeping for one session's
+// on-disk journal: where it's stored and how far it's progressed since the
+// last checkpoint.
+type sessionJournal struct {
+	mu              sync.Mutex
+	path            string
+	nextSeqID       uint64
+	deltasSinceCkpt int
+	lastCheckpoint  time.Time
+}
+
+func (j *sessionJournal) setNextSeqID(seqID uint64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.nextSeqID = seqID
+}
+
+// getOrCreateJournal returns sessionID's sessionJournal, creating it (and
+// its on-disk path alongside the session's existing stream file) on first
+// use.
+func (m *Manager) getOrCreateJournal(sessionID string, sess *session.Session) *sessionJournal {
+	m.journalMu.Lock()
+	defer m.journalMu.Unlock()
+
+	if j, ok := m.journals[sessionID]; ok {
+		return j
+	}
+
+	j := &sessionJournal{path: journalPath(sess)}
+	m.journals[sessionID] = j
+	return j
+}
+
+// journalPath returns where a session's buffer.journal lives, alongside
+// its existing asciinema stream file.
+func journalPath(sess *session.Session) string {
+	return filepath.Join(sess.Path(), "buffer.journal")
+}
+
+// appendJournal records snapshot as the next journal entry for sessionID,
+// writing a full checkpoint instead of a delta once the checkpoint
+// interval (by delta count or elapsed time) is reached, then compacts the
+// journal down to the last two checkpoints. j.mu is held across the whole
+// operation - including the on-disk write and compaction - so two calls for
+// the same session can never interleave their writes to the journal file;
+// writeJournalEntry's header/payload writes aren't atomic on their own.
+func (m *Manager) appendJournal(sessionID string, sess *session.Session, snapshot *terminal.BufferSnapshot) {
+	j := m.getOrCreateJournal(sessionID, sess)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.nextSeqID++
+	seqID := j.nextSeqID
+	writeCheckpoint := j.lastCheckpoint.IsZero() ||
+		j.deltasSinceCkpt >= journalCheckpointEveryDeltas ||
+		time.Since(j.lastCheckpoint) >= journalCheckpointInterval
+
+	var entry journalEntry
+	if writeCheckpoint {
+		entry = journalEntry{Kind: "checkpoint", Checkpoint: &checkpointRecord{SeqID: seqID, Snapshot: snapshot}}
+	} else {
+		lines := make([]LineDelta, 0, len(snapshot.ChangedLines))
+		for row := range snapshot.ChangedLines {
+			if row >= 0 && row < len(snapshot.Cells) {
+				lines = append(lines, LineDelta{Row: row, Cells: snapshot.Cells[row]})
+			}
+		}
+		entry = journalEntry{Kind: "delta", Delta: &deltaRecord{
+			SeqID:        seqID,
+			TimestampMs:  time.Now().UnixMilli(),
+			ChangeFlags:  snapshot.ChangeFlags,
+			ChangedLines: lines,
+		}}
+	}
+
+	if err := writeJournalEntry(j.path, entry); err != nil {
+		log.Printf("[Journal] Failed to append entry for session %s: %v", sessionID, err)
+		return
+	}
+
+	if writeCheckpoint {
+		j.deltasSinceCkpt = 0
+		j.lastCheckpoint = time.Now()
+		if err := compactJournal(j.path); err != nil {
+			log.Printf("[Journal] Failed to compact journal for session %s: %v", sessionID, err)
+		}
+	} else {
+		j.deltasSinceCkpt++
+	}
+}
+
+// ReplayFrom locates the newest checkpoint at or before sinceSeqID in
+// sessionID's journal, applies every later entry forward from it, and
+// returns the snapshot produced by each entry after sinceSeqID plus the
+// journal's current head sequence ID.
+func (m *Manager) ReplayFrom(sessionID string, sinceSeqID uint64) ([]*terminal.BufferSnapshot, uint64, error) {
+	sess, err := m.sessionManager.GetSession(sessionID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries, err := readJournalEntries(journalPath(sess))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(entries) == 0 {
+		return nil, 0, nil
+	}
+
+	checkpointIdx := -1
+	for i, e := range entries {
+		if e.Kind == "checkpoint" && e.seqID() <= sinceSeqID {
+			checkpointIdx = i
+		}
+	}
+	if checkpointIdx == -1 {
+		// Nothing at or before sinceSeqID; fall back to the oldest retained
+		// checkpoint so the caller gets the earliest state we still have
+		// rather than nothing at all.
+		for i, e := range entries {
+			if e.Kind == "checkpoint" {
+				checkpointIdx = i
+				break
+			}
+		}
+	}
+	if checkpointIdx == -1 {
+		return nil, 0, fmt.Errorf("no checkpoint found in journal for session %s", sessionID)
+	}
+
+	snapshot := entries[checkpointIdx].Checkpoint.Snapshot
+	headSeq := entries[checkpointIdx].Checkpoint.SeqID
+
+	var snapshots []*terminal.BufferSnapshot
+	if headSeq > sinceSeqID {
+		snapshots = append(snapshots, snapshot)
+	}
+
+	for _, e := range entries[checkpointIdx+1:] {
+		switch e.Kind {
+		case "checkpoint":
+			snapshot = e.Checkpoint.Snapshot
+			headSeq = e.Checkpoint.SeqID
+		case "delta":
+			snapshot = applyDelta(snapshot, e.Delta)
+			headSeq = e.Delta.SeqID
+		}
+		if headSeq > sinceSeqID {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+
+	return snapshots, headSeq, nil
+}
+
+// rehydrateFromJournal returns the most recent snapshot recorded in
+// sessionID's journal (the last checkpoint plus any trailing deltas), for
+// GetOrCreateBuffer to restore into a freshly created TerminalBuffer after
+// a process restart. It returns a nil snapshot if there's no journal yet.
+func (m *Manager) rehydrateFromJournal(sessionID string, sess *session.Session) (*terminal.BufferSnapshot, uint64, error) {
+	entries, err := readJournalEntries(journalPath(sess))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(entries) == 0 {
+		return nil, 0, nil
+	}
+
+	lastCheckpoint := -1
+	for i, e := range entries {
+		if e.Kind == "checkpoint" {
+			lastCheckpoint = i
+		}
+	}
+	if lastCheckpoint == -1 {
+		return nil, 0, nil
+	}
+
+	snapshot := entries[lastCheckpoint].Checkpoint.Snapshot
+	headSeq := entries[lastCheckpoint].Checkpoint.SeqID
+	for _, e := range entries[lastCheckpoint+1:] {
+		if e.Kind == "delta" {
+			snapshot = applyDelta(snapshot, e.Delta)
+			headSeq = e.Delta.SeqID
+		}
+	}
+
+	return snapshot, headSeq, nil
+}
+
+// applyDelta returns a copy of base with delta's changed lines overlaid.
+func applyDelta(base *terminal.BufferSnapshot, delta *deltaRecord) *terminal.BufferSnapshot {
+	next := *base
+	cells := make([][]terminal.BufferCell, len(base.Cells))
+	copy(cells, base.Cells)
+	for _, line := range delta.ChangedLines {
+		if line.Row >= 0 && line.Row < len(cells) {
+			cells[line.Row] = line.Cells
+		}
+	}
+	next.Cells = cells
+	next.ChangeFlags = delta.ChangeFlags
+	next.SequenceID = delta.SeqID
+	return &next
+}
+
+// writeJournalEntry appends entry to path as a length-prefixed JSON record:
+// a 4-byte big-endian length followed by that many bytes of JSON.
+func writeJournalEntry(path string, entry journalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	if _, err := f.Write(header); err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// readJournalEntries reads every length-prefixed record from path in
+// order. A missing file reads as no entries; a truncated trailing record
+// (e.g. from a write that was interrupted mid-append) is silently dropped
+// rather than treated as an error.
+func readJournalEntries(path string) ([]journalEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []journalEntry
+	for len(data) >= 4 {
+		length := binary.BigEndian.Uint32(data[:4])
+		data = data[4:]
+		if uint64(len(data)) < uint64(length) {
+			break
+		}
+
+		var entry journalEntry
+		if err := json.Unmarshal(data[:length], &entry); err != nil {
+			break
+		}
+		entries = append(entries, entry)
+		data = data[length:]
+	}
+	return entries, nil
+}
+
+// compactJournal rewrites path to retain only entries from the second most
+// recent checkpoint onward, mirroring serf's checkpoint+tail snapshotter:
+// once a new checkpoint lands, anything before the previous one is no
+// longer needed for any ReplayFrom call a client could plausibly make.
+func compactJournal(path string) error {
+	entries, err := readJournalEntries(path)
+	if err != nil {
+		return err
+	}
+
+	var checkpoints []int
+	for i, e := range entries {
+		if e.Kind == "checkpoint" {
+			checkpoints = append(checkpoints, i)
+		}
+	}
+	if len(checkpoints) <= 2 {
+		return nil
+	}
+
+	keepFrom := checkpoints[len(checkpoints)-2]
+	kept := entries[keepFrom:]
+
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range kept {
+		data, err := json.Marshal(e)
+		if err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+		header := make([]byte, 4)
+		binary.BigEndian.PutUint32(header, uint32(len(data)))
+		if _, err := f.Write(header); err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
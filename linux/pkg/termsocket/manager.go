@@ -10,6 +10,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
+
 	"github.com/vibetunnel/linux/pkg/session"
 	"github.com/vibetunnel/linux/pkg/terminal"
 )
@@ -29,13 +31,40 @@ type Manager struct {
 	sessionManager *session.Manager
 	buffers        map[string]*SessionBuffer
 	mu             sync.RWMutex
-	subscribers    map[string][]chan *terminal.BufferSnapshot
-	subMu          sync.RWMutex
+
+	// watches tracks every live WatchSession by ID, with watchesBySession
+	// as a secondary index so notifySubscribers doesn't have to scan all
+	// of them on every change.
+	watches          map[string]*WatchSession
+	watchesBySession map[string]map[string]*WatchSession
+	watchMu          sync.RWMutex
+	nextWatchID      uint64
+
 	shutdownCh     chan struct{}
 	wg             sync.WaitGroup
 	// Debounce timers for buffer notifications (like TypeScript version)
 	notificationTimers map[string]*time.Timer
 	timerMu           sync.RWMutex
+
+	// journals tracks per-session on-disk journal state (sequence counter,
+	// time of the last checkpoint) for the durable buffer.journal files.
+	journals   map[string]*sessionJournal
+	journalMu  sync.Mutex
+
+	// eventState is the in-memory event log backing Events(), the
+	// cursor-paginated long-polling endpoint for buffer changes.
+	eventState *eventState
+
+	// fsWatcher is the single shared fsnotify.Watcher every
+	// monitorSessionPolling call watches its stream file through, lazily
+	// created on first use via sharedFileWatcher. It stays nil (and
+	// fsWatcherInit true) if fsnotify.NewWatcher failed, in which case
+	// monitorSessionPolling falls back to plain polling.
+	fsWatcher     *fsnotify.Watcher
+	fsWatcherInit bool
+	fsWatcherMu   sync.Mutex
+	fsDispatch    map[string]chan fsnotify.Event
+	fsDispatchMu  sync.Mutex
 }
 
 // NewManager creates a new terminal socket manager
@@ -43,9 +72,12 @@ func NewManager(sessionManager *session.Manager) *Manager {
 	return &Manager{
 		sessionManager:     sessionManager,
 		buffers:            make(map[string]*SessionBuffer),
-		subscribers:        make(map[string][]chan *terminal.BufferSnapshot),
+		watches:            make(map[string]*WatchSession),
+		watchesBySession:   make(map[string]map[string]*WatchSession),
 		shutdownCh:         make(chan struct{}),
 		notificationTimers: make(map[string]*time.Timer),
+		journals:           make(map[string]*sessionJournal),
+		eventState:         newEventState(),
 	}
 }
 
@@ -76,6 +108,16 @@ func (m *Manager) GetOrCreateBuffer(sessionID string) (*SessionBuffer, error) {
 		Buffer:  buffer,
 	}
 
+	// Re-hydrate from the on-disk journal (if any) before monitoring starts,
+	// so a vt-server restart doesn't lose scrollback state for a session
+	// that's still alive.
+	if snapshot, headSeq, err := m.rehydrateFromJournal(sessionID, sess); err != nil {
+		log.Printf("[Journal] Failed to rehydrate buffer for session %s: %v", sessionID, err)
+	} else if snapshot != nil {
+		buffer.RestoreSnapshot(snapshot)
+		m.getOrCreateJournal(sessionID, sess).setNextSeqID(headSeq)
+	}
+
 	m.buffers[sessionID] = sb
 
 	// Start monitoring the session's output
@@ -101,27 +143,24 @@ func (m *Manager) GetBufferSnapshot(sessionID string) (*terminal.BufferSnapshot,
 	return sb.Buffer.GetSnapshot(), nil
 }
 
-// SubscribeToBufferChanges subscribes to buffer changes for a session
+// SubscribeToBufferChanges subscribes to every buffer change for a session.
+// It's a back-compat wrapper around Watch for callers that want an
+// unfiltered callback instead of reading a WatchSession's channel
+// themselves.
 func (m *Manager) SubscribeToBufferChanges(sessionID string, callback func(string, *terminal.BufferSnapshot)) (func(), error) {
-	// Ensure buffer exists
-	_, err := m.GetOrCreateBuffer(sessionID)
+	ws, err := m.Watch(WatchOptions{SessionID: sessionID})
 	if err != nil {
 		return nil, err
 	}
 
-	// Create subscription channel
-	ch := make(chan *terminal.BufferSnapshot, 10)
-
-	m.subMu.Lock()
-	m.subscribers[sessionID] = append(m.subscribers[sessionID], ch)
-	m.subMu.Unlock()
-
-	// Start goroutine to handle callbacks
 	done := make(chan struct{})
 	go func() {
 		for {
 			select {
-			case snapshot := <-ch:
+			case snapshot, ok := <-ws.ch:
+				if !ok {
+					return
+				}
 				callback(sessionID, snapshot)
 			case <-done:
 				return
@@ -129,26 +168,9 @@ func (m *Manager) SubscribeToBufferChanges(sessionID string, callback func(strin
 		}
 	}()
 
-	// Return unsubscribe function
 	return func() {
 		close(done)
-		m.subMu.Lock()
-		defer m.subMu.Unlock()
-
-		// Remove channel from subscribers
-		subs := m.subscribers[sessionID]
-		for i, sub := range subs {
-			if sub == ch {
-				m.subscribers[sessionID] = append(subs[:i], subs[i+1:]...)
-				close(ch)
-				break
-			}
-		}
-
-		// Clean up if no more subscribers
-		if len(m.subscribers[sessionID]) == 0 {
-			delete(m.subscribers, sessionID)
-		}
+		m.CloseWatch(ws.ID)
 	}, nil
 }
 
@@ -156,22 +178,30 @@ func (m *Manager) SubscribeToBufferChanges(sessionID string, callback func(strin
 func (m *Manager) monitorSession(sessionID string, sb *SessionBuffer) {
 	// CRITICAL PERFORMANCE FIX: Use direct PTY callbacks like Node.js!
 	// No more file watching - direct memory streaming!
-	
-	// Register for direct PTY output callbacks (like Node.js PTY events)
-	if sessionManager := m.sessionManager; sessionManager != nil {
-		sessionManager.RegisterDirectOutputCallback(sessionID, func(sid string, data []byte) {
-			// Process PTY output immediately (no file I/O delay!)
-			sb.mu.Lock()
-			
-			// Simple approach like Node.js: just write and debounce
-			sb.Buffer.Write(data)
-			sb.mu.Unlock()
-			
-			// Schedule debounced notification (like Node.js 50ms debouncing)
-			m.scheduleBufferNotification(sessionID, sb)
-		})
+
+	// Register for direct PTY output callbacks (like Node.js PTY events).
+	// Without a session manager there's nothing to register the callback
+	// with, so fall back to monitorSessionPolling's file-watch/poll loop
+	// instead of sitting in the liveness-check loop below with no way to
+	// ever see the session's output.
+	sessionManager := m.sessionManager
+	if sessionManager == nil {
+		m.monitorSessionPolling(sessionID, sb)
+		return
 	}
 
+	subID := sessionManager.RegisterDirectOutputCallback(sessionID, func(sid string, data []byte) {
+		// Process PTY output immediately (no file I/O delay!)
+		sb.mu.Lock()
+
+		// Simple approach like Node.js: just write and debounce
+		sb.Buffer.Write(data)
+		sb.mu.Unlock()
+
+		// Schedule debounced notification (like Node.js 50ms debouncing)
+		m.scheduleBufferNotification(sessionID, sb)
+	})
+
 	// Monitor session status
 	sessionCheckTicker := time.NewTicker(5 * time.Second)
 	defer sessionCheckTicker.Stop()
@@ -182,10 +212,8 @@ func (m *Manager) monitorSession(sessionID string, sb *SessionBuffer) {
 			// Check if session is still alive
 			if !sb.Session.IsAlive() {
 				// Unregister callback and clean up when session ends
-				if sessionManager := m.sessionManager; sessionManager != nil {
-					sessionManager.UnregisterDirectOutputCallback(sessionID, nil)
-				}
-				
+				sessionManager.UnregisterDirectOutputCallback(sessionID, subID)
+
 				// Clean up notification timer
 				m.timerMu.Lock()
 				if timer, exists := m.notificationTimers[sessionID]; exists && timer != nil {
@@ -193,9 +221,9 @@ func (m *Manager) monitorSession(sessionID string, sb *SessionBuffer) {
 					delete(m.notificationTimers, sessionID)
 				}
 				m.timerMu.Unlock()
-				
+
 				// No animation timer to clean up (simplified approach)
-				
+
 				m.mu.Lock()
 				delete(m.buffers, sessionID)
 				m.mu.Unlock()
@@ -204,10 +232,8 @@ func (m *Manager) monitorSession(sessionID string, sb *SessionBuffer) {
 
 		case <-m.shutdownCh:
 			// Manager is shutting down
-			if sessionManager := m.sessionManager; sessionManager != nil {
-				sessionManager.UnregisterDirectOutputCallback(sessionID, nil)
-			}
-			
+			sessionManager.UnregisterDirectOutputCallback(sessionID, subID)
+
 			// Clean up notification timer
 			m.timerMu.Lock()
 			if timer, exists := m.notificationTimers[sessionID]; exists && timer != nil {
@@ -223,56 +249,98 @@ func (m *Manager) monitorSession(sessionID string, sb *SessionBuffer) {
 	}
 }
 
-// monitorSessionPolling is a fallback for when file watching isn't available
+// monitorSessionPolling is the fallback for when direct PTY callbacks
+// aren't available: it watches the session's asciinema stream file through
+// the shared fsnotify.Watcher and re-reads it once per debounced burst of
+// write events, instead of the old fixed 50ms sleep loop. If no watcher is
+// available on this platform (or the path can't be watched), it falls back
+// to a coarse filePollFallback-interval poll instead.
 func (m *Manager) monitorSessionPolling(sessionID string, sb *SessionBuffer) {
 	streamPath := sb.Session.StreamOutPath()
 	lastPos := int64(0)
 
-	for {
-		select {
-		case <-m.shutdownCh:
-			// Manager is shutting down
-			return
-		default:
-		}
-
-		// Check if session is still alive
-		if !sb.Session.IsAlive() {
-			break
-		}
+	events, cleanup, watching := m.watchFile(streamPath)
+	defer cleanup()
 
-		// Read new content from stream file
+	readAndNotify := func() {
 		update, newPos, err := readStreamContent(streamPath, lastPos)
 		if err != nil && !os.IsNotExist(err) {
 			log.Printf("Error reading stream content: %v", err)
 		}
+		lastPos = newPos
 
-		if update != nil && (len(update.OutputData) > 0 || update.Resize != nil) {
-			// Update buffer
-			sb.mu.Lock()
-			if len(update.OutputData) > 0 {
-				sb.Buffer.Write(update.OutputData)
-			}
-			if update.Resize != nil {
-				sb.Buffer.Resize(update.Resize.Width, update.Resize.Height)
-			}
-			snapshot := sb.Buffer.GetSnapshot()
-			sb.mu.Unlock()
+		if update == nil || (len(update.OutputData) == 0 && update.Resize == nil) {
+			return
+		}
 
-			// Notify subscribers
-			m.notifySubscribers(sessionID, snapshot)
+		sb.mu.Lock()
+		if len(update.OutputData) > 0 {
+			sb.Buffer.Write(update.OutputData)
+		}
+		if update.Resize != nil {
+			sb.Buffer.Resize(update.Resize.Width, update.Resize.Height)
 		}
+		snapshot := sb.Buffer.GetSnapshot()
+		sb.mu.Unlock()
 
-		lastPos = newPos
+		m.notifySubscribers(sessionID, sb, snapshot)
+	}
+
+	// Pick up anything already written before we started watching.
+	readAndNotify()
 
-		// Small delay to prevent busy waiting
-		time.Sleep(50 * time.Millisecond)
+	poll := time.NewTicker(filePollFallback)
+	defer poll.Stop()
+	if watching {
+		poll.Stop()
 	}
 
-	// Clean up when session ends
-	m.mu.Lock()
-	delete(m.buffers, sessionID)
-	m.mu.Unlock()
+	// debounce coalesces a burst of write events into a single read,
+	// mirroring the polling+inotify hybrid tail libraries use. It starts
+	// stopped and drained so the first <-debounce.C never fires spuriously.
+	debounce := time.NewTimer(fileWatchDebounce)
+	if !debounce.Stop() {
+		<-debounce.C
+	}
+	defer debounce.Stop()
+	pending := false
+
+	aliveCheck := time.NewTicker(1 * time.Second)
+	defer aliveCheck.Stop()
+
+	for {
+		select {
+		case <-m.shutdownCh:
+			return
+
+		case <-aliveCheck.C:
+			if !sb.Session.IsAlive() {
+				m.mu.Lock()
+				delete(m.buffers, sessionID)
+				m.mu.Unlock()
+				return
+			}
+
+		case <-events:
+			if !debounce.Stop() {
+				select {
+				case <-debounce.C:
+				default:
+				}
+			}
+			debounce.Reset(fileWatchDebounce)
+			pending = true
+
+		case <-debounce.C:
+			if pending {
+				pending = false
+				readAndNotify()
+			}
+
+		case <-poll.C:
+			readAndNotify()
+		}
+	}
 }
 
 // scheduleBufferNotification schedules a debounced buffer notification (like TypeScript version)
@@ -309,7 +377,7 @@ func (m *Manager) scheduleBufferNotification(sessionID string, sb *SessionBuffer
 		
 		// Only notify if something actually changed (vt10x pattern)
 		if hasChanged {
-			m.notifySubscribers(sessionID, snapshot)
+			m.notifySubscribers(sessionID, sb, snapshot)
 		}
 		
 		// Clean up timer
@@ -319,19 +387,41 @@ func (m *Manager) scheduleBufferNotification(sessionID string, sb *SessionBuffer
 	})
 }
 
-// notifySubscribers sends buffer updates to all subscribers
-func (m *Manager) notifySubscribers(sessionID string, snapshot *terminal.BufferSnapshot) {
-	m.subMu.RLock()
-	subs := m.subscribers[sessionID]
-	m.subMu.RUnlock()
+// notifySubscribers sends buffer updates to every watch on sessionID whose
+// mask isn't filtering out this change, eagerly cleaning up any watch whose
+// context has been cancelled.
+func (m *Manager) notifySubscribers(sessionID string, sb *SessionBuffer, snapshot *terminal.BufferSnapshot) {
+	m.watchMu.RLock()
+	watches := m.watchesBySession[sessionID]
+	var stale []string
+	for id, ws := range watches {
+		select {
+		case <-ws.ctx.Done():
+			stale = append(stale, id)
+			continue
+		default:
+		}
+
+		// A zero mask means "no filter" so unfiltered watchers (e.g. the
+		// SubscribeToBufferChanges wrapper) still see everything.
+		if ws.Mask != 0 && snapshot.ChangeFlags&ws.Mask == 0 {
+			continue
+		}
 
-	for _, ch := range subs {
 		select {
-		case ch <- snapshot:
+		case ws.ch <- snapshot:
 		default:
 			// Channel full, skip
 		}
 	}
+	m.watchMu.RUnlock()
+
+	for _, id := range stale {
+		m.CloseWatch(id)
+	}
+
+	m.appendJournal(sessionID, sb.Session, snapshot)
+	m.appendEvent(sessionID, EventBufferUpdate, snapshot)
 }
 
 // StreamUpdate represents an update from the stream file
@@ -442,20 +532,26 @@ func (m *Manager) Shutdown() {
 	// Wait for all monitors to finish
 	m.wg.Wait()
 
-	// Close all subscriber channels
-	m.subMu.Lock()
-	for _, subs := range m.subscribers {
-		for _, ch := range subs {
-			close(ch)
-		}
+	// Close all watch channels
+	m.watchMu.Lock()
+	for _, ws := range m.watches {
+		close(ws.ch)
 	}
-	m.subscribers = make(map[string][]chan *terminal.BufferSnapshot)
-	m.subMu.Unlock()
+	m.watches = make(map[string]*WatchSession)
+	m.watchesBySession = make(map[string]map[string]*WatchSession)
+	m.watchMu.Unlock()
 
 	// Clear buffers
 	m.mu.Lock()
 	m.buffers = make(map[string]*SessionBuffer)
 	m.mu.Unlock()
 
+	// Stop the shared fsnotify watcher, if one was ever started
+	m.fsWatcherMu.Lock()
+	if m.fsWatcher != nil {
+		m.fsWatcher.Close()
+	}
+	m.fsWatcherMu.Unlock()
+
 	log.Println("Terminal buffer manager shutdown complete")
 }
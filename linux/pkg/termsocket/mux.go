@@ -0,0 +1,290 @@
+package termsocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/vibetunnel/linux/pkg/terminal"
+)
+
+// muxVersion is the only wire version ServeMux currently speaks.
+const muxVersion = 1
+
+// muxHeaderSize is 1 byte version + 1 byte cmd + 4 byte stream ID + 4 byte
+// big-endian length.
+const muxHeaderSize = 1 + 1 + 4 + 4
+
+// maxMuxPayloadLength bounds a single mux frame's payload so a corrupt or
+// hostile peer can't make readMuxFrame allocate unbounded memory.
+const maxMuxPayloadLength = 16 * 1024 * 1024
+
+// streamBacklogCap is how many un-sent snapshots a single stream's token
+// bucket holds before enqueue starts dropping the oldest one. Snapshots are
+// idempotent full buffer states, so dropping a stale one just means the
+// next PSH carries more catch-up.
+const streamBacklogCap = 4
+
+// muxCmd identifies the kind of a muxFrame.
+type muxCmd byte
+
+const (
+	// muxCmdSYN subscribes streamID to a session's buffer changes; its
+	// payload is the session ID.
+	muxCmdSYN muxCmd = iota + 1
+	// muxCmdFIN unsubscribes streamID; no further PSH frames follow it.
+	muxCmdFIN
+	// muxCmdPSH carries a buffer snapshot delta, server to client only.
+	muxCmdPSH
+	// muxCmdNOP is a keepalive, echoed back by ServeMux with the same
+	// stream ID.
+	muxCmdNOP
+)
+
+// muxFrame is one message multiplexed over a MuxSession's connection.
+type muxFrame struct {
+	Version  uint8
+	Cmd      muxCmd
+	StreamID uint32
+	Payload  []byte
+}
+
+// readMuxFrame reads the next muxFrame from r, blocking until one is fully
+// available.
+func readMuxFrame(r io.Reader) (muxFrame, error) {
+	header := make([]byte, muxHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return muxFrame{}, err
+	}
+
+	length := binary.BigEndian.Uint32(header[6:10])
+	if length > maxMuxPayloadLength {
+		return muxFrame{}, fmt.Errorf("termsocket: mux frame length %d exceeds max %d", length, maxMuxPayloadLength)
+	}
+
+	f := muxFrame{
+		Version:  header[0],
+		Cmd:      muxCmd(header[1]),
+		StreamID: binary.BigEndian.Uint32(header[2:6]),
+	}
+	if length > 0 {
+		f.Payload = make([]byte, length)
+		if _, err := io.ReadFull(r, f.Payload); err != nil {
+			return muxFrame{}, err
+		}
+	}
+	return f, nil
+}
+
+// writeMuxFrame writes f to w. It does not buffer; callers that need to
+// write from multiple goroutines must serialize their own calls.
+func writeMuxFrame(w io.Writer, f muxFrame) error {
+	if len(f.Payload) > maxMuxPayloadLength {
+		return fmt.Errorf("termsocket: mux frame length %d exceeds max %d", len(f.Payload), maxMuxPayloadLength)
+	}
+
+	header := make([]byte, muxHeaderSize)
+	header[0] = muxVersion
+	header[1] = byte(f.Cmd)
+	binary.BigEndian.PutUint32(header[2:6], f.StreamID)
+	binary.BigEndian.PutUint32(header[6:10], uint32(len(f.Payload)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if len(f.Payload) == 0 {
+		return nil
+	}
+	_, err := w.Write(f.Payload)
+	return err
+}
+
+// muxStream is one client-chosen stream ID attached to a session's buffer
+// changes within a MuxSession.
+type muxStream struct {
+	streamID    uint32
+	sessionID   string
+	unsubscribe func()
+
+	// backlog is this stream's token bucket: enqueue drops the oldest
+	// pending snapshot rather than block when it's full, so a slow reader
+	// on one stream can't stall the others.
+	backlog chan *terminal.BufferSnapshot
+	done    chan struct{}
+}
+
+func (s *muxStream) enqueue(snapshot *terminal.BufferSnapshot) {
+	for {
+		select {
+		case s.backlog <- snapshot:
+			return
+		default:
+			select {
+			case <-s.backlog:
+			default:
+			}
+		}
+	}
+}
+
+// MuxSession multiplexes subscriptions to many sessions' buffer changes
+// over a single net.Conn, smux-style, so a client doesn't need one
+// connection per session it watches.
+type MuxSession struct {
+	conn    net.Conn
+	m       *Manager
+	writeCh chan muxFrame
+
+	mu      sync.Mutex
+	streams map[uint32]*muxStream
+}
+
+// ServeMux reads SYN/FIN/PSH/NOP frames from conn until it closes or a
+// fatal read error occurs, subscribing/unsubscribing sessions on
+// m via SubscribeToBufferChanges as SYN/FIN frames arrive and streaming
+// buffer snapshots back as PSH frames tagged with the client-chosen stream
+// ID. All writes go through a single shared goroutine so one slow stream
+// can't stall the others' frames on this connection.
+func ServeMux(conn net.Conn, m *Manager) error {
+	ms := &MuxSession{
+		conn:    conn,
+		m:       m,
+		writeCh: make(chan muxFrame, 64),
+		streams: make(map[uint32]*muxStream),
+	}
+	defer ms.closeAllStreams()
+
+	writeDone := make(chan struct{})
+	go func() {
+		defer close(writeDone)
+		for frame := range ms.writeCh {
+			if err := writeMuxFrame(conn, frame); err != nil {
+				return
+			}
+		}
+	}()
+	defer func() {
+		close(ms.writeCh)
+		<-writeDone
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		frame, err := readMuxFrame(reader)
+		if err != nil {
+			return err
+		}
+
+		switch frame.Cmd {
+		case muxCmdSYN:
+			ms.openStream(frame.StreamID, string(frame.Payload))
+		case muxCmdFIN:
+			ms.closeStream(frame.StreamID)
+		case muxCmdNOP:
+			ms.send(muxFrame{Version: muxVersion, Cmd: muxCmdNOP, StreamID: frame.StreamID})
+		case muxCmdPSH:
+			// PSH is server-to-client only; a client sending one is
+			// ignored rather than treated as a protocol error.
+		}
+	}
+}
+
+// openStream subscribes streamID to sessionID's buffer changes, starting a
+// goroutine that drains the resulting snapshots onto the shared write
+// channel as PSH frames.
+func (ms *MuxSession) openStream(streamID uint32, sessionID string) {
+	ms.mu.Lock()
+	if _, exists := ms.streams[streamID]; exists {
+		ms.mu.Unlock()
+		return
+	}
+	stream := &muxStream{
+		streamID:  streamID,
+		sessionID: sessionID,
+		backlog:   make(chan *terminal.BufferSnapshot, streamBacklogCap),
+		done:      make(chan struct{}),
+	}
+	ms.streams[streamID] = stream
+	ms.mu.Unlock()
+
+	unsubscribe, err := ms.m.SubscribeToBufferChanges(sessionID, func(_ string, snapshot *terminal.BufferSnapshot) {
+		stream.enqueue(snapshot)
+	})
+	if err != nil {
+		ms.mu.Lock()
+		delete(ms.streams, streamID)
+		ms.mu.Unlock()
+		return
+	}
+
+	ms.mu.Lock()
+	stream.unsubscribe = unsubscribe
+	ms.mu.Unlock()
+
+	go ms.drainStream(stream)
+}
+
+// drainStream forwards snapshots queued for stream onto the shared write
+// channel as PSH frames until closeStream signals it to stop.
+func (ms *MuxSession) drainStream(stream *muxStream) {
+	for {
+		select {
+		case snapshot := <-stream.backlog:
+			data, err := json.Marshal(snapshot)
+			if err != nil {
+				continue
+			}
+			ms.send(muxFrame{Version: muxVersion, Cmd: muxCmdPSH, StreamID: stream.streamID, Payload: data})
+		case <-stream.done:
+			return
+		}
+	}
+}
+
+func (ms *MuxSession) closeStream(streamID uint32) {
+	ms.mu.Lock()
+	stream, exists := ms.streams[streamID]
+	if exists {
+		delete(ms.streams, streamID)
+	}
+	ms.mu.Unlock()
+
+	if !exists {
+		return
+	}
+	if stream.unsubscribe != nil {
+		stream.unsubscribe()
+	}
+	close(stream.done)
+}
+
+func (ms *MuxSession) closeAllStreams() {
+	ms.mu.Lock()
+	streams := make([]*muxStream, 0, len(ms.streams))
+	for _, s := range ms.streams {
+		streams = append(streams, s)
+	}
+	ms.streams = make(map[uint32]*muxStream)
+	ms.mu.Unlock()
+
+	for _, s := range streams {
+		if s.unsubscribe != nil {
+			s.unsubscribe()
+		}
+		close(s.done)
+	}
+}
+
+// send queues f for the shared write goroutine, dropping it if that
+// goroutine is too far behind rather than blocking the reader loop that
+// drives every stream on this connection.
+func (ms *MuxSession) send(f muxFrame) {
+	select {
+	case ms.writeCh <- f:
+	default:
+	}
+}
@@ -0,0 +1,143 @@
+package termsocket
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/vibetunnel/linux/pkg/terminal"
+)
+
+// ChangeFlags is a bitmask of terminal.Changed* values, used by
+// WatchOptions.Mask to filter which buffer changes a watch receives.
+type ChangeFlags = uint32
+
+// WatchOptions configures a call to Watch.
+type WatchOptions struct {
+	// ID is this watch's handle, returned in WatchInfo and passed to
+	// CloseWatch. If empty, Watch assigns one.
+	ID string
+	// SessionID is the session to watch buffer changes for.
+	SessionID string
+	// Mask restricts delivery to snapshots whose ChangeFlags intersect it.
+	// Zero means no filter - every change is delivered.
+	Mask ChangeFlags
+	// Ctx, if non-nil, is checked on every notification; once it's done the
+	// watch is closed eagerly rather than waiting for an explicit
+	// CloseWatch call.
+	Ctx context.Context
+}
+
+// WatchSession is one live subscription to a session's buffer changes,
+// named and filterable so an admin endpoint can enumerate and forcibly
+// evict misbehaving watchers.
+type WatchSession struct {
+	ID        string
+	SessionID string
+	Mask      ChangeFlags
+
+	ch        chan *terminal.BufferSnapshot
+	ctx       context.Context
+	createdAt time.Time
+}
+
+// Updates returns the channel snapshots matching this watch's mask are
+// delivered on. It's closed when the watch is closed.
+func (ws *WatchSession) Updates() <-chan *terminal.BufferSnapshot {
+	return ws.ch
+}
+
+// WatchInfo is a read-only snapshot of a WatchSession's bookkeeping,
+// returned by ListWatches.
+type WatchInfo struct {
+	ID        string      `json:"id"`
+	SessionID string      `json:"sessionId"`
+	Mask      ChangeFlags `json:"mask"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// Watch starts a new named watch on opts.SessionID's buffer changes,
+// creating the session's buffer if it doesn't exist yet.
+func (m *Manager) Watch(opts WatchOptions) (*WatchSession, error) {
+	if opts.SessionID == "" {
+		return nil, fmt.Errorf("termsocket: watch requires a session ID")
+	}
+	if _, err := m.GetOrCreateBuffer(opts.SessionID); err != nil {
+		return nil, err
+	}
+
+	ctx := opts.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	id := opts.ID
+	if id == "" {
+		id = fmt.Sprintf("watch-%d", atomic.AddUint64(&m.nextWatchID, 1))
+	}
+
+	ws := &WatchSession{
+		ID:        id,
+		SessionID: opts.SessionID,
+		Mask:      opts.Mask,
+		ch:        make(chan *terminal.BufferSnapshot, 10),
+		ctx:       ctx,
+		createdAt: time.Now(),
+	}
+
+	m.watchMu.Lock()
+	defer m.watchMu.Unlock()
+
+	if _, exists := m.watches[id]; exists {
+		return nil, fmt.Errorf("termsocket: watch id %q already exists", id)
+	}
+	m.watches[id] = ws
+	if m.watchesBySession[opts.SessionID] == nil {
+		m.watchesBySession[opts.SessionID] = make(map[string]*WatchSession)
+	}
+	m.watchesBySession[opts.SessionID][id] = ws
+
+	return ws, nil
+}
+
+// CloseWatch ends the watch with the given ID, closing its channel. It's a
+// no-op if no such watch exists (e.g. it already closed itself after its
+// context was cancelled).
+func (m *Manager) CloseWatch(id string) {
+	m.watchMu.Lock()
+	ws, exists := m.watches[id]
+	if exists {
+		delete(m.watches, id)
+		if bySession, ok := m.watchesBySession[ws.SessionID]; ok {
+			delete(bySession, id)
+			if len(bySession) == 0 {
+				delete(m.watchesBySession, ws.SessionID)
+			}
+		}
+	}
+	m.watchMu.Unlock()
+
+	if exists {
+		close(ws.ch)
+	}
+}
+
+// ListWatches returns every live watch on sessionID, for an admin endpoint
+// to inspect or evict stuck clients.
+func (m *Manager) ListWatches(sessionID string) []WatchInfo {
+	m.watchMu.RLock()
+	defer m.watchMu.RUnlock()
+
+	watches := m.watchesBySession[sessionID]
+	infos := make([]WatchInfo, 0, len(watches))
+	for _, ws := range watches {
+		infos = append(infos, WatchInfo{
+			ID:        ws.ID,
+			SessionID: ws.SessionID,
+			Mask:      ws.Mask,
+			CreatedAt: ws.createdAt,
+		})
+	}
+	return infos
+}
@@ -0,0 +1,121 @@
+// Package client is a Go client for pkg/tunnel, letting a headless machine
+// (a CI runner, a remote dev box) multiplex every one of its live sessions
+// onto a single connection to a central VibeTunnel host, instead of opening
+// one WebSocket per session. The tunnel frames carry no credentials, and Dial
+// opens a plain TCP connection; New wraps whatever net.Conn it is given.
+package client
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/vibetunnel/linux/pkg/tunnel"
+)
+
+// Client is one multiplexed tunnel connection carrying zero or more
+// attached sessions.
+type Client struct {
+	conn net.Conn
+	enc  *tunnel.Encoder
+	dec  *tunnel.Decoder
+
+	encMu sync.Mutex
+
+	nextHandle uint64
+
+	mu     sync.Mutex
+	onData map[uint64]func(data []byte)
+}
+
+// Dial opens a TCP connection to addr and wraps it as a Client.
+func Dial(addr string) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return New(conn), nil
+}
+
+// New wraps an already-established connection as a Client and starts
+// reading frames from it in the background.
+func New(conn net.Conn) *Client {
+	c := &Client{
+		conn:   conn,
+		enc:    tunnel.NewEncoder(conn),
+		dec:    tunnel.NewDecoder(conn),
+		onData: make(map[uint64]func(data []byte)),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Open attaches sessionID to a new handle on this connection. onData is
+// called with each chunk of PTY output as it arrives; it must not block.
+// Open returns the handle to use with Input/Resize/Close.
+func (c *Client) Open(sessionID string, onData func(data []byte)) (uint64, error) {
+	handle := atomic.AddUint64(&c.nextHandle, 1)
+
+	c.mu.Lock()
+	c.onData[handle] = onData
+	c.mu.Unlock()
+
+	return handle, c.send(tunnel.Frame{Type: tunnel.FrameOpen, Handle: handle, Payload: []byte(sessionID)})
+}
+
+// Close detaches handle. The remote session itself keeps running; this
+// only tears down this connection's attachment to it.
+func (c *Client) Close(handle uint64) error {
+	c.mu.Lock()
+	delete(c.onData, handle)
+	c.mu.Unlock()
+
+	return c.send(tunnel.Frame{Type: tunnel.FrameClose, Handle: handle})
+}
+
+// Input sends data as PTY input to the session attached at handle.
+func (c *Client) Input(handle uint64, data []byte) error {
+	return c.send(tunnel.Frame{Type: tunnel.FrameInput, Handle: handle, Payload: data})
+}
+
+// Resize sends a terminal resize for the session attached at handle.
+func (c *Client) Resize(handle uint64, cols, rows int) error {
+	return c.send(tunnel.Frame{Type: tunnel.FrameResize, Handle: handle, Payload: tunnel.EncodeResizePayload(cols, rows)})
+}
+
+// Ping sends a keepalive frame; the server echoes it back but otherwise
+// Client doesn't surface it since it carries no session-specific payload.
+func (c *Client) Ping(handle uint64) error {
+	return c.send(tunnel.Frame{Type: tunnel.FramePing, Handle: handle})
+}
+
+// Disconnect closes the underlying connection, ending readLoop.
+func (c *Client) Disconnect() error {
+	return c.conn.Close()
+}
+
+func (c *Client) send(f tunnel.Frame) error {
+	c.encMu.Lock()
+	defer c.encMu.Unlock()
+	return c.enc.Encode(f)
+}
+
+func (c *Client) readLoop() {
+	for {
+		frame, err := c.dec.Decode()
+		if err != nil {
+			return
+		}
+
+		if frame.Type != tunnel.FrameData {
+			continue
+		}
+
+		c.mu.Lock()
+		onData := c.onData[frame.Handle]
+		c.mu.Unlock()
+		if onData != nil {
+			onData(frame.Payload)
+		}
+	}
+}
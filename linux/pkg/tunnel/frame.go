@@ -0,0 +1,134 @@
+// Package tunnel implements a length-prefixed framed protocol that lets a
+// single connection carry many sessions' PTY I/O at once, instead of one
+// WebSocket per session.
+package tunnel
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// FrameType identifies the kind of a tunnel Frame.
+type FrameType byte
+
+const (
+	// FrameData carries PTY output from server to client.
+	FrameData FrameType = iota + 1
+	// FrameResize carries a terminal resize from client to server.
+	FrameResize
+	// FrameOpen attaches a session ID to a handle, server to client or
+	// client to server depending on who dialed.
+	FrameOpen
+	// FrameClose detaches a handle; no further frames for it will be sent.
+	FrameClose
+	// FrameInput carries PTY input from client to server.
+	FrameInput
+	// FramePing is a keepalive, echoed back by the receiver.
+	FramePing
+)
+
+// maxFrameLength bounds a single frame's payload so a corrupt or hostile
+// peer can't make Decode allocate unbounded memory.
+const maxFrameLength = 16 * 1024 * 1024
+
+// frameHeaderSize is 1 byte type + 8 byte handle + 4 byte big-endian length.
+const frameHeaderSize = 13
+
+// Frame is one message on the tunnel wire.
+type Frame struct {
+	Type FrameType
+	// Handle identifies which multiplexed session this frame belongs to -
+	// a short-lived ID assigned by the OPEN side, not the session's own ID.
+	Handle  uint64
+	Payload []byte
+}
+
+// Encoder writes Frames to the wire as 1-byte type + 8-byte handle +
+// 4-byte big-endian length + payload.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns an Encoder that writes frames to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes f to the wire. It does not buffer; callers that need to
+// encode from multiple goroutines must serialize their own calls.
+func (e *Encoder) Encode(f Frame) error {
+	if len(f.Payload) > maxFrameLength {
+		return fmt.Errorf("tunnel: payload length %d exceeds max %d", len(f.Payload), maxFrameLength)
+	}
+
+	header := make([]byte, frameHeaderSize)
+	header[0] = byte(f.Type)
+	binary.BigEndian.PutUint64(header[1:9], f.Handle)
+	binary.BigEndian.PutUint32(header[9:13], uint32(len(f.Payload)))
+
+	if _, err := e.w.Write(header); err != nil {
+		return err
+	}
+	if len(f.Payload) == 0 {
+		return nil
+	}
+	_, err := e.w.Write(f.Payload)
+	return err
+}
+
+// Decoder reads Frames from the wire written by an Encoder.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder returns a Decoder that reads frames from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads and returns the next Frame, blocking until one is fully
+// available.
+func (d *Decoder) Decode() (Frame, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		return Frame{}, err
+	}
+
+	length := binary.BigEndian.Uint32(header[9:13])
+	if length > maxFrameLength {
+		return Frame{}, fmt.Errorf("tunnel: frame length %d exceeds max %d", length, maxFrameLength)
+	}
+
+	f := Frame{
+		Type:   FrameType(header[0]),
+		Handle: binary.BigEndian.Uint64(header[1:9]),
+	}
+	if length > 0 {
+		f.Payload = make([]byte, length)
+		if _, err := io.ReadFull(d.r, f.Payload); err != nil {
+			return Frame{}, err
+		}
+	}
+	return f, nil
+}
+
+// EncodeResizePayload packs a terminal size into a FrameResize payload.
+func EncodeResizePayload(cols, rows int) []byte {
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint32(payload[0:4], uint32(cols))
+	binary.BigEndian.PutUint32(payload[4:8], uint32(rows))
+	return payload
+}
+
+// DecodeResizePayload unpacks a FrameResize payload produced by
+// EncodeResizePayload.
+func DecodeResizePayload(payload []byte) (cols, rows int, err error) {
+	if len(payload) != 8 {
+		return 0, 0, fmt.Errorf("tunnel: resize payload must be 8 bytes, got %d", len(payload))
+	}
+	cols = int(binary.BigEndian.Uint32(payload[0:4]))
+	rows = int(binary.BigEndian.Uint32(payload[4:8]))
+	return cols, rows, nil
+}
@@ -0,0 +1,128 @@
+package tunnel
+
+import (
+	"io"
+	"log"
+	"sync"
+
+	"github.com/vibetunnel/linux/pkg/session"
+)
+
+// Server multiplexes many sessions' PTY I/O over a single framed
+// connection: OPEN/CLOSE frames attach and detach a session to a
+// connection-local handle, DATA frames carry PTY output out to the peer,
+// and INPUT/RESIZE frames carry input back from it.
+type Server struct {
+	sessionManager *session.Manager
+}
+
+// NewServer returns a Server that serves sessions out of sessionManager.
+func NewServer(sessionManager *session.Manager) *Server {
+	return &Server{sessionManager: sessionManager}
+}
+
+// Serve reads and handles frames from conn until Decode returns an error
+// (including the peer closing the connection), then unwinds every handle
+// this connection opened.
+func (s *Server) Serve(conn io.ReadWriter) error {
+	dec := NewDecoder(conn)
+
+	var encMu sync.Mutex
+	enc := NewEncoder(conn)
+	send := func(f Frame) {
+		encMu.Lock()
+		defer encMu.Unlock()
+		if err := enc.Encode(f); err != nil {
+			log.Printf("[Tunnel] Failed to write frame: %v", err)
+		}
+	}
+
+	var mu sync.Mutex
+	sessionIDs := make(map[uint64]string)
+	subs := make(map[uint64]session.SubscriptionID)
+
+	detach := func(handle uint64) {
+		mu.Lock()
+		sessionID, ok := sessionIDs[handle]
+		subID, subOk := subs[handle]
+		delete(sessionIDs, handle)
+		delete(subs, handle)
+		mu.Unlock()
+		if ok && subOk {
+			s.sessionManager.UnregisterRawPTYCallback(sessionID, subID)
+		}
+	}
+
+	defer func() {
+		mu.Lock()
+		handles := make([]uint64, 0, len(sessionIDs))
+		for handle := range sessionIDs {
+			handles = append(handles, handle)
+		}
+		mu.Unlock()
+		for _, handle := range handles {
+			detach(handle)
+		}
+	}()
+
+	for {
+		frame, err := dec.Decode()
+		if err != nil {
+			return err
+		}
+
+		switch frame.Type {
+		case FrameOpen:
+			sessionID := string(frame.Payload)
+			subID := s.sessionManager.RegisterRawPTYCallback(sessionID, func(sid string, data []byte) {
+				send(Frame{Type: FrameData, Handle: frame.Handle, Payload: data})
+			})
+
+			mu.Lock()
+			sessionIDs[frame.Handle] = sessionID
+			subs[frame.Handle] = subID
+			mu.Unlock()
+
+		case FrameClose:
+			detach(frame.Handle)
+
+		case FrameInput:
+			mu.Lock()
+			sessionID, ok := sessionIDs[frame.Handle]
+			mu.Unlock()
+			if !ok {
+				continue
+			}
+			sess, err := s.sessionManager.GetSession(sessionID)
+			if err != nil {
+				continue
+			}
+			if _, err := sess.Write(frame.Payload); err != nil {
+				log.Printf("[Tunnel] Failed to write input to session %s: %v", sessionID, err)
+			}
+
+		case FrameResize:
+			mu.Lock()
+			sessionID, ok := sessionIDs[frame.Handle]
+			mu.Unlock()
+			if !ok {
+				continue
+			}
+			cols, rows, err := DecodeResizePayload(frame.Payload)
+			if err != nil {
+				log.Printf("[Tunnel] Malformed resize frame for handle %d: %v", frame.Handle, err)
+				continue
+			}
+			sess, err := s.sessionManager.GetSession(sessionID)
+			if err != nil {
+				continue
+			}
+			if err := sess.Resize(cols, rows); err != nil {
+				log.Printf("[Tunnel] Failed to resize session %s: %v", sessionID, err)
+			}
+
+		case FramePing:
+			send(Frame{Type: FramePing, Handle: frame.Handle})
+		}
+	}
+}